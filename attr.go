@@ -0,0 +1,196 @@
+package irr
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+)
+
+// jsonAttr 是 slog.Attr 的 JSON 表示，kind 用于在解码时恢复值的类型
+type jsonAttr struct {
+	Key   string          `json:"key"`
+	Kind  string          `json:"kind"`
+	Value json.RawMessage `json:"value"`
+}
+
+// jsonAttrKindSecret 是 Secret 值的 kind，Value 是原始值编码后的 jsonAttr
+const jsonAttrKindSecret = "Secret"
+
+// SetAttr
+// the implementation of IAttributor, val can be any value or a slog.Value,
+// setting an existing key replaces its value and keeps its position
+func (ir *BasicIrr) SetAttr(key string, val any) {
+	ir.setAttr(slog.Any(key, val))
+}
+
+func (ir *BasicIrr) setAttr(attr slog.Attr) {
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+
+	var newAttrs []slog.Attr
+	if currentAttrs := ir.attrs.Load(); currentAttrs != nil {
+		newAttrs = make([]slog.Attr, len(*currentAttrs), len(*currentAttrs)+1)
+		copy(newAttrs, *currentAttrs)
+	}
+	for i := range newAttrs {
+		if newAttrs[i].Key == attr.Key {
+			newAttrs[i] = attr
+			ir.attrs.Store(&newAttrs)
+			return
+		}
+	}
+	newAttrs = append(newAttrs, attr)
+	ir.attrs.Store(&newAttrs)
+}
+
+// GetAttr
+// the implementation of IAttributor
+func (ir *BasicIrr) GetAttr(key string) (slog.Value, bool) {
+	if attrs := ir.attrs.Load(); attrs != nil {
+		for _, attr := range *attrs {
+			if attr.Key == key {
+				return attr.Value, true
+			}
+		}
+	}
+	return slog.Value{}, false
+}
+
+// Attrs
+// the implementation of IAttributor, attrs are returned in the order they are set
+func (ir *BasicIrr) Attrs() []slog.Attr {
+	attrs := ir.attrs.Load()
+	if attrs == nil {
+		return nil
+	}
+	result := make([]slog.Attr, len(*attrs))
+	copy(result, *attrs)
+	return result
+}
+
+func (ir *BasicIrr) writeAttrsTo(sb *strings.Builder, reveal bool) {
+	attrs := ir.attrs.Load()
+	if attrs == nil {
+		return
+	}
+	list := *attrs
+	if !reveal {
+		list = redactAttrs(list)
+	}
+	for _, attr := range list {
+		sb.WriteRune('[')
+		sb.WriteString(attr.Key)
+		sb.WriteRune(':')
+		if reveal {
+			sb.WriteString(revealAttrValue(attr.Value))
+		} else {
+			sb.WriteString(attr.Value.Resolve().String())
+		}
+		sb.WriteString("] ")
+	}
+}
+
+// newJSONAttrs reveal 为 true 时 Secret 值以 jsonAttrKindSecret 保留原始值
+func newJSONAttrs(attrs []slog.Attr, reveal bool) []jsonAttr {
+	result := make([]jsonAttr, 0, len(attrs))
+	for _, attr := range attrs {
+		result = append(result, newJSONAttr(attr, reveal))
+	}
+	return result
+}
+
+func newJSONAttr(attr slog.Attr, reveal bool) jsonAttr {
+	if secret, ok := attr.Value.Any().(SecretValue); ok && reveal {
+		inner, _ := json.Marshal(newJSONAttr(slog.Any(attr.Key, secret.v), true))
+		return jsonAttr{Key: attr.Key, Kind: jsonAttrKindSecret, Value: inner}
+	}
+	v := attr.Value.Resolve()
+	var raw any
+	switch v.Kind() {
+	case slog.KindString:
+		raw = v.String()
+	case slog.KindInt64:
+		raw = v.Int64()
+	case slog.KindUint64:
+		raw = v.Uint64()
+	case slog.KindFloat64:
+		if f := v.Float64(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return jsonAttr{Key: attr.Key, Kind: slog.KindString.String(), Value: marshalString(v.String())}
+		}
+		raw = v.Float64()
+	case slog.KindBool:
+		raw = v.Bool()
+	case slog.KindDuration:
+		raw = int64(v.Duration())
+	case slog.KindTime:
+		raw = v.Time().Format(time.RFC3339Nano)
+	case slog.KindGroup:
+		raw = newJSONAttrs(v.Group(), reveal)
+	default:
+		raw = v.Any()
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		// 无法编码为 JSON 的值降级为字符串
+		return jsonAttr{Key: attr.Key, Kind: slog.KindString.String(), Value: marshalString(v.String())}
+	}
+	return jsonAttr{Key: attr.Key, Kind: v.Kind().String(), Value: data}
+}
+
+func (a jsonAttr) toAttr() slog.Attr {
+	switch a.Kind {
+	case slog.KindString.String():
+		var s string
+		_ = json.Unmarshal(a.Value, &s)
+		return slog.String(a.Key, s)
+	case slog.KindInt64.String():
+		var i int64
+		_ = json.Unmarshal(a.Value, &i)
+		return slog.Int64(a.Key, i)
+	case slog.KindUint64.String():
+		var u uint64
+		_ = json.Unmarshal(a.Value, &u)
+		return slog.Uint64(a.Key, u)
+	case slog.KindFloat64.String():
+		var f float64
+		_ = json.Unmarshal(a.Value, &f)
+		return slog.Float64(a.Key, f)
+	case slog.KindBool.String():
+		var b bool
+		_ = json.Unmarshal(a.Value, &b)
+		return slog.Bool(a.Key, b)
+	case slog.KindDuration.String():
+		var d int64
+		_ = json.Unmarshal(a.Value, &d)
+		return slog.Duration(a.Key, time.Duration(d))
+	case slog.KindTime.String():
+		var s string
+		_ = json.Unmarshal(a.Value, &s)
+		t, _ := time.Parse(time.RFC3339Nano, s)
+		return slog.Time(a.Key, t)
+	case jsonAttrKindSecret:
+		var inner jsonAttr
+		_ = json.Unmarshal(a.Value, &inner)
+		return slog.Any(a.Key, Secret(inner.toAttr().Value.Any()))
+	case slog.KindGroup.String():
+		var group []jsonAttr
+		_ = json.Unmarshal(a.Value, &group)
+		attrs := make([]any, 0, len(group))
+		for _, attr := range group {
+			attrs = append(attrs, attr.toAttr())
+		}
+		return slog.Group(a.Key, attrs...)
+	default:
+		// 其他类型无法恢复原始类型，解码为 JSON 的通用表示
+		var v any
+		_ = json.Unmarshal(a.Value, &v)
+		return slog.Any(a.Key, v)
+	}
+}
+
+func marshalString(v string) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -0,0 +1,107 @@
+package irr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type attrUser struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestSetAttr(t *testing.T) {
+	err := Error("payment failed")
+	err.SetAttr("uid", int64(42))
+	err.SetAttr("elapsed", 1500*time.Millisecond)
+	err.SetAttr("amount", 9.5)
+	err.SetAttr("region", "eu")
+
+	attrs := err.Attrs()
+	assert.Equal(t, 4, len(attrs))
+	assert.Equal(t, "uid", attrs[0].Key)
+	assert.Equal(t, slog.KindInt64, attrs[0].Value.Kind())
+	assert.Equal(t, int64(42), attrs[0].Value.Int64())
+	assert.Equal(t, slog.KindDuration, attrs[1].Value.Kind())
+	assert.Equal(t, 1500*time.Millisecond, attrs[1].Value.Duration())
+
+	v, ok := err.GetAttr("amount")
+	assert.True(t, ok)
+	assert.Equal(t, 9.5, v.Float64())
+	_, ok = err.GetAttr("missing")
+	assert.False(t, ok)
+
+	// 重复设置时替换原值并保留位置
+	err.SetAttr("uid", int64(43))
+	attrs = err.Attrs()
+	assert.Equal(t, 4, len(attrs))
+	assert.Equal(t, int64(43), attrs[0].Value.Int64())
+
+	// 返回的是副本
+	attrs[0] = slog.Int("uid", 0)
+	v, _ = err.GetAttr("uid")
+	assert.Equal(t, int64(43), v.Int64())
+
+	assert.Equal(t, "payment failed[uid:43] [elapsed:1.5s] [amount:9.5] [region:eu] ", err.Error())
+	assert.Nil(t, Error("no attrs").Attrs())
+}
+
+func TestGetTagWithAttr(t *testing.T) {
+	err := Error("e")
+	err.SetAttr("region", "eu")
+	err.SetAttr("uid", 42)
+	assert.Equal(t, []string{"eu"}, err.GetTag("region"))
+	assert.Nil(t, err.GetTag("uid"))
+	assert.Nil(t, err.GetTag("missing"))
+
+	err.SetTag("region", "us")
+	assert.Equal(t, []string{"us", "eu"}, err.GetTag("region"))
+}
+
+func TestAttrJSON(t *testing.T) {
+	at := time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC)
+	err := Wrap(errors.New("std"), "outer")
+	err.SetAttr("uid", int64(42))
+	err.SetAttr("count", uint64(7))
+	err.SetAttr("elapsed", 2*time.Second)
+	err.SetAttr("ratio", 0.25)
+	err.SetAttr("ok", false)
+	err.SetAttr("at", at)
+	err.SetAttr("name", "alice")
+	err.SetAttr("req", slog.GroupValue(slog.String("method", "GET"), slog.Int("status", 500)))
+	err.SetAttr("user", attrUser{ID: 1, Name: "bob"})
+	err.SetAttr("nan", math.NaN())
+	err.SetAttr("ch", make(chan int))
+
+	data, e := json.Marshal(err)
+	assert.NoError(t, e)
+
+	decoded := &BasicIrr{}
+	assert.NoError(t, json.Unmarshal(data, decoded))
+	attrs := decoded.Attrs()
+	assert.Equal(t, 11, len(attrs))
+
+	get := func(key string) slog.Value {
+		v, ok := decoded.GetAttr(key)
+		assert.True(t, ok, key)
+		return v
+	}
+	assert.Equal(t, int64(42), get("uid").Int64())
+	assert.Equal(t, uint64(7), get("count").Uint64())
+	assert.Equal(t, 2*time.Second, get("elapsed").Duration())
+	assert.Equal(t, 0.25, get("ratio").Float64())
+	assert.Equal(t, false, get("ok").Bool())
+	assert.True(t, at.Equal(get("at").Time()))
+	assert.Equal(t, "alice", get("name").String())
+	assert.Equal(t, "[method=GET status=500]", fmt.Sprint(get("req").Group()))
+	assert.Equal(t, map[string]any{"id": float64(1), "name": "bob"}, get("user").Any())
+	assert.Equal(t, "NaN", get("nan").String())
+	assert.Equal(t, slog.KindString, get("ch").Kind())
+}
@@ -0,0 +1,296 @@
+// Package breaker provides a circuit breaker whose trip decisions are driven by
+// the codes of IRR error chains.
+//
+// Only errors whose nearest code falls into the configured failure classes are
+// counted as failures, so that, for example, 5xx codes trip the breaker while 4xx
+// codes, which say nothing about the health of the dependency, don't. Errors out
+// of the classes are regarded as successes.
+//
+// After FailureThreshold consecutive failures the breaker opens and rejects calls
+// with ErrCircuitOpen. When OpenTimeout has passed it turns half-open and lets
+// HalfOpenProbes calls through, it closes when all of them succeed and opens again
+// on the first failure. Time is read from a Clock only, tests inject a fake one.
+//
+// Rejections of a breaker are never counted as failures by another breaker, so
+// nested breakers don't trip each other.
+package breaker
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+)
+
+type (
+	// State is the state of a Breaker.
+	State int32
+
+	// CodeRange is an inclusive range of codes, a failure class.
+	CodeRange struct {
+		Min irc.Code
+		Max irc.Code
+	}
+
+	// Clock tells the time, see SystemClock.
+	Clock interface {
+		Now() time.Time
+	}
+
+	// Settings configures a Breaker, zero fields fall back to the defaults.
+	Settings struct {
+		// Name identifies the breaker in ErrCircuitOpen errors and callbacks.
+		Name string
+		// FailureCodes are the failure classes, DefaultFailureCodes when it's empty.
+		// Use CodeRange{0, 0} to count errors without codes as failures as well.
+		FailureCodes []CodeRange
+		// FailureThreshold is the number of consecutive failures that opens the
+		// breaker, DefaultFailureThreshold when it's not positive.
+		FailureThreshold int
+		// OpenTimeout is how long the breaker stays open before probing,
+		// DefaultOpenTimeout when it's not positive.
+		OpenTimeout time.Duration
+		// HalfOpenProbes is the number of calls let through when half-open, all
+		// of them must succeed to close the breaker, 1 when it's not positive.
+		HalfOpenProbes int
+		// OnStateChange is called after each state change, outside the lock of the breaker.
+		OnStateChange func(name string, from, to State)
+		// Clock is SystemClock when it's nil.
+		Clock Clock
+	}
+
+	// Breaker is a circuit breaker, it's safe for concurrent use.
+	Breaker struct {
+		s Settings
+
+		mu         sync.Mutex
+		state      State
+		generation uint64
+		failures   int
+		probes     int
+		successes  int
+		openedAt   time.Time
+	}
+
+	systemClock struct{}
+)
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+const (
+	// TagBreaker is the tag key of the breaker name of ErrCircuitOpen errors.
+	TagBreaker = "breaker.name"
+
+	// CodeCircuitOpen is the code of the errors caused by ErrCircuitOpen, it's
+	// mapped to HTTP 503 by irrhttp.
+	CodeCircuitOpen irc.Code = 503
+
+	DefaultFailureThreshold = 5
+	DefaultOpenTimeout      = 30 * time.Second
+)
+
+var (
+	// ErrCircuitOpen is the cause of the errors returned when a breaker rejects a call,
+	// use errors.Is to check it. It carries no code, so that other errors with
+	// CodeCircuitOpen don't match it.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+
+	// DefaultFailureCodes counts 5xx codes as failures.
+	DefaultFailureCodes = []CodeRange{{Min: 500, Max: 599}}
+
+	// SystemClock is the Clock of the time package.
+	SystemClock Clock = systemClock{}
+)
+
+// New creates a closed breaker.
+func New(s Settings) *Breaker {
+	if len(s.FailureCodes) == 0 {
+		s.FailureCodes = DefaultFailureCodes
+	}
+	if s.FailureThreshold <= 0 {
+		s.FailureThreshold = DefaultFailureThreshold
+	}
+	if s.OpenTimeout <= 0 {
+		s.OpenTimeout = DefaultOpenTimeout
+	}
+	if s.HalfOpenProbes <= 0 {
+		s.HalfOpenProbes = 1
+	}
+	if s.Clock == nil {
+		s.Clock = SystemClock
+	}
+	return &Breaker{s: s}
+}
+
+// Name returns the name of the breaker.
+func (b *Breaker) Name() string {
+	return b.s.Name
+}
+
+// State returns the current state, an open breaker whose timeout has passed is half-open.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	from := b.state
+	to := b.refresh()
+	b.mu.Unlock()
+	b.notify(from, to)
+	return to
+}
+
+// IsFailure reports whether the nearest code of err falls into the failure classes.
+// Rejections of breakers (ErrCircuitOpen) are not failures.
+func (b *Breaker) IsFailure(err error) bool {
+	if err == nil || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	code := irc.Code(irr.NearestCodeOf(err))
+	for _, r := range b.s.FailureCodes {
+		if r.Contains(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow asks the breaker for a call. When it's rejected, an error caused by
+// ErrCircuitOpen is returned, with the breaker name tagged and the remaining
+// open time as the retry-after hint. Otherwise done must be called exactly once
+// with the result of the call.
+func (b *Breaker) Allow() (done func(err error), err error) {
+	generation, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+	return func(err error) {
+		b.report(generation, b.IsFailure(err))
+	}, nil
+}
+
+// Do calls fn if the breaker allows it and reports its result, a panic of fn
+// is counted as a failure and re-panicked.
+func (b *Breaker) Do(fn func() error) error {
+	generation, err := b.allow()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			b.report(generation, true)
+			panic(r)
+		}
+	}()
+	err = fn()
+	b.report(generation, b.IsFailure(err))
+	return err
+}
+
+// Contains reports whether code is in the range.
+func (r CodeRange) Contains(code irc.Code) bool {
+	return code >= r.Min && code <= r.Max
+}
+
+// String implements fmt.Stringer.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	}
+	return "state(" + strconv.Itoa(int(s)) + ")"
+}
+
+// allow returns the generation of the allowed call, or the rejection.
+func (b *Breaker) allow() (generation uint64, err error) {
+	b.mu.Lock()
+	from := b.state
+	to := b.refresh()
+	switch {
+	case to == StateOpen:
+		err = b.openErr()
+	case to == StateHalfOpen && b.probes >= b.s.HalfOpenProbes:
+		err = b.openErr()
+	case to == StateHalfOpen:
+		b.probes++
+	}
+	generation = b.generation
+	b.mu.Unlock()
+	b.notify(from, to)
+	return generation, err
+}
+
+// report records the result of a call allowed in the generation, results of
+// previous generations are ignored.
+func (b *Breaker) report(generation uint64, failure bool) {
+	b.mu.Lock()
+	from := b.state
+	if generation == b.generation {
+		switch b.state {
+		case StateClosed:
+			if !failure {
+				b.failures = 0
+			} else if b.failures++; b.failures >= b.s.FailureThreshold {
+				b.setState(StateOpen)
+			}
+		case StateHalfOpen:
+			if failure {
+				b.setState(StateOpen)
+			} else if b.successes++; b.successes >= b.s.HalfOpenProbes {
+				b.setState(StateClosed)
+			}
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+// refresh turns an open breaker half-open when its timeout has passed, it must
+// be called with the lock held.
+func (b *Breaker) refresh() State {
+	if b.state == StateOpen && !b.s.Clock.Now().Before(b.openedAt.Add(b.s.OpenTimeout)) {
+		b.setState(StateHalfOpen)
+	}
+	return b.state
+}
+
+// setState starts a new generation, it must be called with the lock held.
+func (b *Breaker) setState(state State) {
+	b.state = state
+	b.generation++
+	b.failures, b.probes, b.successes = 0, 0, 0
+	if state == StateOpen {
+		b.openedAt = b.s.Clock.Now()
+	}
+}
+
+// openErr must be called with the lock held.
+func (b *Breaker) openErr() irr.IRR {
+	err := CodeCircuitOpen.Wrap(ErrCircuitOpen, "breaker %s", b.s.Name)
+	err.SetTag(TagBreaker, b.s.Name)
+	if wait := b.openedAt.Add(b.s.OpenTimeout).Sub(b.s.Clock.Now()); wait > 0 {
+		err.SetRetryAfter(wait)
+	}
+	return err
+}
+
+func (b *Breaker) notify(from, to State) {
+	if from != to && b.s.OnStateChange != nil {
+		b.s.OnStateChange(b.s.Name, from, to)
+	}
+}
+
+// Now
+// the implementation of Clock
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,231 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type transition struct {
+	name     string
+	from, to State
+}
+
+func newTestBreaker(s Settings) (*Breaker, *fakeClock, *[]transition) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	var transitions []transition
+	s.Clock = clock
+	s.OnStateChange = func(name string, from, to State) {
+		transitions = append(transitions, transition{name, from, to})
+	}
+	return New(s), clock, &transitions
+}
+
+func fail(code irc.Code) func() error {
+	return func() error {
+		return irr.Wrap(code.Error("boom"), "call")
+	}
+}
+
+func succeed() error {
+	return nil
+}
+
+func TestBreaker_Trip(t *testing.T) {
+	b, _, transitions := newTestBreaker(Settings{Name: "db", FailureThreshold: 3})
+
+	// 4xx 不计入失败，且会重置连续失败计数
+	for i := 0; i < 10; i++ {
+		assert.Error(t, b.Do(fail(404)))
+	}
+	assert.Equal(t, StateClosed, b.State())
+
+	assert.Error(t, b.Do(fail(500)))
+	assert.Error(t, b.Do(fail(502)))
+	assert.Error(t, b.Do(fail(400)))
+	assert.Error(t, b.Do(fail(503)))
+	assert.Error(t, b.Do(fail(500)))
+	assert.Equal(t, StateClosed, b.State())
+
+	// 没有错误码的错误默认不计入
+	assert.Error(t, b.Do(func() error { return errors.New("eof") }))
+	assert.Equal(t, StateClosed, b.State())
+
+	assert.Error(t, b.Do(fail(500)))
+	assert.Error(t, b.Do(fail(500)))
+	assert.Equal(t, StateClosed, b.State())
+	assert.Error(t, b.Do(fail(599)))
+	assert.Equal(t, StateOpen, b.State())
+	assert.Equal(t, []transition{{"db", StateClosed, StateOpen}}, *transitions)
+}
+
+func TestBreaker_OpenError(t *testing.T) {
+	b, clock, _ := newTestBreaker(Settings{Name: "payments", FailureThreshold: 1, OpenTimeout: 10 * time.Second})
+	assert.Error(t, b.Do(fail(500)))
+
+	clock.Advance(4 * time.Second)
+	called := false
+	err := b.Do(func() error {
+		called = true
+		return nil
+	})
+	assert.False(t, called)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+
+	var ir irr.IRR
+	assert.True(t, errors.As(err, &ir))
+	assert.Equal(t, int64(CodeCircuitOpen), ir.NearestCode())
+	assert.Equal(t, []string{"payments"}, ir.GetTag(TagBreaker))
+	// 剩余的打开时间作为重试提示
+	wait, ok := irr.RetryAfterOf(err)
+	assert.True(t, ok)
+	assert.Equal(t, 6*time.Second, wait)
+	assert.True(t, irr.IsRetryable(err))
+
+	done, err := b.Allow()
+	assert.Nil(t, done)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+}
+
+func TestErrCircuitOpen_Foreign503(t *testing.T) {
+	// 其他来源的 503 错误不是熔断器的拒绝
+	foreign := CodeCircuitOpen.Error("upstream unavailable")
+	assert.False(t, errors.Is(foreign, ErrCircuitOpen))
+	assert.False(t, errors.Is(irr.Wrap(foreign, "call"), ErrCircuitOpen))
+
+	b, _, _ := newTestBreaker(Settings{FailureThreshold: 1})
+	assert.True(t, b.IsFailure(foreign))
+}
+
+func TestBreaker_Nested(t *testing.T) {
+	inner, _, _ := newTestBreaker(Settings{Name: "inner", FailureThreshold: 1})
+	outer, _, _ := newTestBreaker(Settings{Name: "outer", FailureThreshold: 2})
+	assert.Error(t, inner.Do(fail(500)))
+	assert.Equal(t, StateOpen, inner.State())
+
+	// 内层熔断器的拒绝不计为外层的失败
+	for i := 0; i < 3; i++ {
+		err := outer.Do(func() error { return inner.Do(fail(500)) })
+		assert.True(t, errors.Is(err, ErrCircuitOpen))
+		assert.False(t, outer.IsFailure(err))
+	}
+	assert.Equal(t, StateClosed, outer.State())
+}
+
+func TestBreaker_HalfOpen(t *testing.T) {
+	b, clock, transitions := newTestBreaker(Settings{
+		Name:             "api",
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+		HalfOpenProbes:   2,
+	})
+	assert.Error(t, b.Do(fail(500)))
+	assert.Error(t, b.Do(fail(500)))
+	assert.Equal(t, StateOpen, b.State())
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	// 半开状态下最多放行 HalfOpenProbes 个探测
+	done1, err := b.Allow()
+	assert.NoError(t, err)
+	done2, err := b.Allow()
+	assert.NoError(t, err)
+	_, err = b.Allow()
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+
+	// 探测失败重新打开
+	done1(irr.ErrorC(500, "still down"))
+	assert.Equal(t, StateOpen, b.State())
+	// 上一代的结果被忽略
+	done2(nil)
+	assert.Equal(t, StateOpen, b.State())
+
+	clock.Advance(time.Minute)
+	assert.NoError(t, b.Do(succeed))
+	assert.Equal(t, StateHalfOpen, b.State())
+	// 4xx 视为成功
+	assert.Error(t, b.Do(fail(404)))
+	assert.Equal(t, StateClosed, b.State())
+
+	assert.Equal(t, []transition{
+		{"api", StateClosed, StateOpen},
+		{"api", StateOpen, StateHalfOpen},
+		{"api", StateHalfOpen, StateOpen},
+		{"api", StateOpen, StateHalfOpen},
+		{"api", StateHalfOpen, StateClosed},
+	}, *transitions)
+}
+
+func TestBreaker_FailureCodes(t *testing.T) {
+	b, _, _ := newTestBreaker(Settings{
+		FailureThreshold: 1,
+		FailureCodes:     []CodeRange{{Min: 0, Max: 0}, {Min: 429, Max: 429}},
+	})
+	assert.False(t, b.IsFailure(nil))
+	assert.True(t, b.IsFailure(errors.New("eof")))
+	assert.True(t, b.IsFailure(irr.Wrap(irr.ErrorC(429, "slow down"), "call")))
+	assert.False(t, b.IsFailure(irr.ErrorC(500, "down")))
+}
+
+func TestBreaker_Panic(t *testing.T) {
+	b, _, _ := newTestBreaker(Settings{FailureThreshold: 1})
+	assert.Panics(t, func() {
+		_ = b.Do(func() error { panic("boom") })
+	})
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestBreaker_Defaults(t *testing.T) {
+	b := New(Settings{Name: "x"})
+	assert.Equal(t, "x", b.Name())
+	assert.Equal(t, DefaultFailureThreshold, b.s.FailureThreshold)
+	assert.Equal(t, DefaultOpenTimeout, b.s.OpenTimeout)
+	assert.Equal(t, 1, b.s.HalfOpenProbes)
+	assert.Equal(t, SystemClock, b.s.Clock)
+
+	assert.Equal(t, "closed", StateClosed.String())
+	assert.Equal(t, "half-open", StateHalfOpen.String())
+	assert.Equal(t, "open", StateOpen.String())
+	assert.Equal(t, "state(9)", State(9).String())
+}
+
+func TestBreaker_Concurrent(t *testing.T) {
+	b, _, _ := newTestBreaker(Settings{FailureThreshold: 801})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = b.Do(fail(500))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, StateClosed, b.State())
+	assert.Error(t, b.Do(fail(500)))
+	assert.Equal(t, StateOpen, b.State())
+}
@@ -0,0 +1,191 @@
+package irr
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryClass 错误的重试分类
+type RetryClass int32
+
+const (
+	// ClassUnknown 未分类，IsRetryable 视为不可重试
+	ClassUnknown RetryClass = iota
+	// ClassPermanent 永久错误，重试不会成功
+	ClassPermanent
+	// ClassRetryable 可以重试
+	ClassRetryable
+	// ClassTemporary 临时错误，如资源暂时不可用，可以重试
+	ClassTemporary
+	// ClassTimeout 超时，可以重试
+	ClassTimeout
+)
+
+// codeRetryClasses 保存按错误码设置的分类，key 为 int64
+var codeRetryClasses sync.Map
+
+// String 实现 fmt.Stringer
+func (c RetryClass) String() string {
+	switch c {
+	case ClassUnknown:
+		return "unknown"
+	case ClassPermanent:
+		return "permanent"
+	case ClassRetryable:
+		return "retryable"
+	case ClassTemporary:
+		return "temporary"
+	case ClassTimeout:
+		return "timeout"
+	}
+	return "retry_class(" + strconv.Itoa(int(c)) + ")"
+}
+
+// Retryable 判断该分类是否可以重试，retryable、temporary 与 timeout 均可重试
+func (c RetryClass) Retryable() bool {
+	return c == ClassRetryable || c == ClassTemporary || c == ClassTimeout
+}
+
+// parseRetryClass 是 String 的逆操作，无法识别时返回 ClassUnknown
+func parseRetryClass(s string) RetryClass {
+	for c := ClassPermanent; c <= ClassTimeout; c++ {
+		if c.String() == s {
+			return c
+		}
+	}
+	return ClassUnknown
+}
+
+// SetRetryClass
+// the implementation of IRetryClassifier, it marks this layer explicitly,
+// ClassUnknown removes the marker
+func (ir *BasicIrr) SetRetryClass(class RetryClass) IRR {
+	ir.retryClass = class
+	return ir
+}
+
+// SetRetryAfter
+// the implementation of IRetryClassifier, it sets the hint of how long to wait
+// before retrying, an unclassified layer is marked as ClassRetryable as well
+func (ir *BasicIrr) SetRetryAfter(d time.Duration) IRR {
+	ir.retryAfter = d
+	if ir.retryClass == ClassUnknown && d > 0 {
+		ir.retryClass = ClassRetryable
+	}
+	return ir
+}
+
+// RetryClass
+// the implementation of IRetryClassifier, it returns the explicit marker of this layer only
+func (ir *BasicIrr) RetryClass() RetryClass {
+	return ir.retryClass
+}
+
+// RetryAfter
+// the implementation of IRetryClassifier, it returns the hint of this layer only
+func (ir *BasicIrr) RetryAfter() time.Duration {
+	return ir.retryAfter
+}
+
+// SetCodeRetryClass 设置错误码的分类，ClassUnknown 会移除设置
+// irc.ApplyRetryClasses 会将注册时 Meta.Retryable 为 true 的错误码设置为 ClassRetryable
+func SetCodeRetryClass(code int64, class RetryClass) {
+	if class == ClassUnknown {
+		codeRetryClasses.Delete(code)
+		return
+	}
+	codeRetryClasses.Store(code, class)
+}
+
+// CodeRetryClass 返回错误码的分类，未设置时返回 ClassUnknown
+func CodeRetryClass(code int64) RetryClass {
+	if class, ok := codeRetryClasses.Load(code); ok {
+		return class.(RetryClass)
+	}
+	return ClassUnknown
+}
+
+// Classify 返回错误链的重试分类
+// 首先沿错误链查找最近的显式标记：某一层的 SetRetryClass/SetRetryAfter，
+// 实现了 RetryClass() RetryClass 的外部错误，或者设置了分类的错误码；
+// 没有显式标记时自动识别：上下文已超时的 ContextualIrr 与 context.DeadlineExceeded
+// 为 ClassTimeout，Timeout() 返回 true 的错误（如 net.Error）为 ClassTimeout，
+// Temporary() 返回 true 的错误为 ClassTemporary，context.Canceled 为 ClassPermanent
+func Classify(err error) RetryClass {
+	class := ClassUnknown
+	_ = walkToRoot(err, func(err error) error {
+		if class = explicitRetryClass(err); class != ClassUnknown {
+			return errStopWalk
+		}
+		return nil
+	})
+	if class != ClassUnknown {
+		return class
+	}
+	_ = walkToRoot(err, func(err error) error {
+		if class = detectRetryClass(err); class != ClassUnknown {
+			return errStopWalk
+		}
+		return nil
+	})
+	return class
+}
+
+// IsRetryable 判断错误链是否可以重试，见 Classify
+func IsRetryable(err error) bool {
+	return Classify(err).Retryable()
+}
+
+// IsTimeout 判断错误链是否被分类为超时，见 Classify
+func IsTimeout(err error) bool {
+	return Classify(err) == ClassTimeout
+}
+
+// RetryAfterOf 返回错误链中最近的重试等待时间提示
+func RetryAfterOf(err error) (d time.Duration, ok bool) {
+	_ = walkToRoot(err, func(err error) error {
+		if r, isRetry := err.(interface{ RetryAfter() time.Duration }); isRetry && r.RetryAfter() > 0 {
+			d, ok = r.RetryAfter(), true
+			return errStopWalk
+		}
+		return nil
+	})
+	return d, ok
+}
+
+func explicitRetryClass(err error) RetryClass {
+	if c, ok := err.(interface{ RetryClass() RetryClass }); ok {
+		if class := c.RetryClass(); class != ClassUnknown {
+			return class
+		}
+	}
+	if code := codeOf(err); code != 0 {
+		return CodeRetryClass(code)
+	}
+	return ClassUnknown
+}
+
+func detectRetryClass(err error) RetryClass {
+	if ce, ok := err.(*ContextualIrr); ok {
+		if ce.ctx != nil && errors.Is(ce.ctx.Err(), context.DeadlineExceeded) {
+			return ClassTimeout
+		}
+		return ClassUnknown
+	}
+	switch err {
+	case context.DeadlineExceeded:
+		return ClassTimeout
+	case context.Canceled:
+		return ClassPermanent
+	}
+	if t, ok := err.(interface{ Timeout() bool }); ok && t.Timeout() {
+		return ClassTimeout
+	}
+	if t, ok := err.(interface{ Temporary() bool }); ok && t.Temporary() {
+		return ClassTemporary
+	}
+	return ClassUnknown
+}
@@ -0,0 +1,135 @@
+package irr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testNetError 模拟 net.Error
+type testNetError struct {
+	timeout, temporary bool
+}
+
+var _ net.Error = testNetError{}
+
+func (e testNetError) Error() string   { return "net error" }
+func (e testNetError) Timeout() bool   { return e.timeout }
+func (e testNetError) Temporary() bool { return e.temporary }
+
+func TestRetryClass_String(t *testing.T) {
+	for _, c := range []RetryClass{ClassUnknown, ClassPermanent, ClassRetryable, ClassTemporary, ClassTimeout} {
+		if c != ClassUnknown {
+			assert.Equal(t, c, parseRetryClass(c.String()))
+		}
+	}
+	assert.Equal(t, "timeout", ClassTimeout.String())
+	assert.Equal(t, "retry_class(9)", RetryClass(9).String())
+	assert.Equal(t, ClassUnknown, parseRetryClass("retry_class(9)"))
+	assert.False(t, ClassUnknown.Retryable())
+	assert.False(t, ClassPermanent.Retryable())
+	assert.True(t, ClassTemporary.Retryable())
+	assert.False(t, RetryClass(9).Retryable())
+}
+
+func TestClassify_Markers(t *testing.T) {
+	assert.Equal(t, ClassUnknown, Classify(nil))
+	assert.Equal(t, ClassUnknown, Classify(errors.New("plain")))
+	assert.False(t, IsRetryable(Error("plain")))
+
+	inner := Error("db busy").SetRetryClass(ClassTemporary)
+	assert.Equal(t, ClassTemporary, inner.RetryClass())
+	assert.True(t, IsRetryable(Wrap(inner, "query")))
+
+	// 最近的显式标记优先
+	outer := Wrap(inner, "create order").SetRetryClass(ClassPermanent)
+	assert.Equal(t, ClassPermanent, Classify(outer))
+	assert.False(t, IsRetryable(fmt.Errorf("rpc: %w", outer)))
+	outer.SetRetryClass(ClassUnknown)
+	assert.Equal(t, ClassTemporary, Classify(outer))
+
+	// 显式标记优先于自动识别，即使自动识别的错误更近
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	marked := WrapWithContext(expired, Error("invalid input").SetRetryClass(ClassPermanent), "handle")
+	assert.Equal(t, ClassPermanent, Classify(marked))
+
+	// 聚合错误按深度优先的顺序查找
+	joined := Join(Error("a"), Error("b").SetRetryClass(ClassTimeout))
+	assert.True(t, IsTimeout(Wrap(joined, "batch")))
+}
+
+func TestClassify_Codes(t *testing.T) {
+	SetCodeRetryClass(96503, ClassTemporary)
+	defer SetCodeRetryClass(96503, ClassUnknown)
+
+	assert.Equal(t, ClassTemporary, CodeRetryClass(96503))
+	assert.True(t, IsRetryable(Wrap(ErrorC(96503, "unavailable"), "call")))
+	// 同一层的显式标记优先于错误码
+	assert.False(t, IsRetryable(ErrorC(96503, "unavailable").SetRetryClass(ClassPermanent)))
+
+	SetCodeRetryClass(96503, ClassUnknown)
+	assert.Equal(t, ClassUnknown, CodeRetryClass(96503))
+	assert.False(t, IsRetryable(ErrorC(96503, "unavailable")))
+}
+
+func TestClassify_Detect(t *testing.T) {
+	assert.Equal(t, ClassTimeout, Classify(context.DeadlineExceeded))
+	assert.Equal(t, ClassTimeout, Classify(Wrap(fmt.Errorf("dial: %w", context.DeadlineExceeded), "x")))
+	assert.Equal(t, ClassPermanent, Classify(Wrap(context.Canceled, "x")))
+	assert.Equal(t, ClassTimeout, Classify(&net.OpError{Op: "dial", Err: testNetError{timeout: true}}))
+	assert.Equal(t, ClassTemporary, Classify(Wrap(testNetError{temporary: true}, "x")))
+	assert.Equal(t, ClassUnknown, Classify(testNetError{}))
+	assert.Equal(t, ClassTimeout, Classify(os.ErrDeadlineExceeded))
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	assert.True(t, IsTimeout(Wrap(ErrorWithContext(ctx, "expired"), "handle")))
+	assert.Equal(t, ClassUnknown, Classify(ErrorWithContext(context.Background(), "alive")))
+	// 已取消的上下文不视为超时
+	canceled, cancel2 := context.WithCancel(context.Background())
+	cancel2()
+	assert.Equal(t, ClassUnknown, Classify(ErrorWithContext(canceled, "canceled")))
+}
+
+func TestRetryAfter(t *testing.T) {
+	_, ok := RetryAfterOf(Error("x"))
+	assert.False(t, ok)
+
+	inner := ErrorC(429, "rate limited").SetRetryAfter(2 * time.Second)
+	assert.Equal(t, ClassRetryable, inner.RetryClass())
+	assert.Equal(t, 2*time.Second, inner.RetryAfter())
+	d, ok := RetryAfterOf(Wrap(inner, "call"))
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, d)
+
+	// 已有的分类不会被覆盖
+	assert.Equal(t, ClassTemporary, Error("x").SetRetryClass(ClassTemporary).SetRetryAfter(time.Second).RetryClass())
+}
+
+func TestRetryClass_Encoding(t *testing.T) {
+	err := Wrap(Error("busy").SetRetryClass(ClassTemporary).SetRetryAfter(1500*time.Millisecond), "call")
+
+	data, _ := MarshalErrorJSON(err)
+	assert.Contains(t, string(data), `"retry":"temporary","retry_after":1500000000`)
+	decodedJSON, _ := UnmarshalErrorJSON(data)
+	assert.Equal(t, ClassTemporary, Classify(decodedJSON.Err))
+	d, _ := RetryAfterOf(decodedJSON.Err)
+	assert.Equal(t, 1500*time.Millisecond, d)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, Encode(buf, err))
+	decoded, _ := Decode(buf)
+	assert.Equal(t, ClassTemporary, Classify(decoded.Err))
+	d, _ = RetryAfterOf(decoded.Err)
+	assert.Equal(t, 1500*time.Millisecond, d)
+
+	assert.Contains(t, fmt.Sprintf("%#v", errors.Unwrap(err)), "Retry:temporary, RetryAfter:1.5s")
+}
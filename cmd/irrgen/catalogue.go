@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// Catalogue is the source of truth of the codes, it's loaded from a YAML or JSON file.
+	Catalogue struct {
+		// Package is the package name of the generated Go file.
+		Package string `yaml:"package" json:"package"`
+		// ConstPrefix is prepended to the CamelCase name of each code, "Code" by default.
+		ConstPrefix string `yaml:"const_prefix" json:"const_prefix"`
+		// DocsBaseURL is used to build the docs url of codes without one, as DocsBaseURL + Name.
+		DocsBaseURL string `yaml:"docs_base_url" json:"docs_base_url"`
+
+		Ranges []Range `yaml:"ranges" json:"ranges"`
+		Codes  []Entry `yaml:"codes" json:"codes"`
+	}
+
+	// Range is a named code range, it provides defaults for the codes inside it.
+	Range struct {
+		Name        string `yaml:"name" json:"name"`
+		From        int64  `yaml:"from" json:"from"`
+		To          int64  `yaml:"to" json:"to"`
+		Category    string `yaml:"category" json:"category,omitempty"`
+		HTTPStatus  int    `yaml:"http_status" json:"http_status,omitempty"`
+		Description string `yaml:"description" json:"description,omitempty"`
+	}
+
+	// Entry is a code in the catalogue.
+	Entry struct {
+		Code        int64  `yaml:"code" json:"code"`
+		Name        string `yaml:"name" json:"name"`
+		Const       string `yaml:"const" json:"const,omitempty"`
+		Message     string `yaml:"message" json:"message,omitempty"`
+		Description string `yaml:"description" json:"description,omitempty"`
+		Category    string `yaml:"category" json:"category,omitempty"`
+		HTTPStatus  int    `yaml:"http_status" json:"http_status,omitempty"`
+		GRPCCode    uint32 `yaml:"grpc_code" json:"grpc_code,omitempty"`
+		Retryable   bool   `yaml:"retryable" json:"retryable,omitempty"`
+		Severity    string `yaml:"severity" json:"severity,omitempty"`
+		DocsURL     string `yaml:"docs_url" json:"docs_url,omitempty"`
+
+		// Range is the name of the range the code belongs to, it's filled by Normalize.
+		Range string `yaml:"-" json:"range,omitempty"`
+	}
+)
+
+const defaultConstPrefix = "Code"
+
+var (
+	ErrInvalidCatalogue = errors.New("invalid catalogue")
+
+	namePattern  = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	severities = map[string]irc.Severity{
+		"info":     irc.SeverityInfo,
+		"warning":  irc.SeverityWarning,
+		"error":    irc.SeverityError,
+		"critical": irc.SeverityCritical,
+	}
+)
+
+// LoadCatalogue reads a catalogue file, files with the .json extension are
+// decoded as JSON, others as YAML. The returned catalogue is normalized.
+func LoadCatalogue(path string) (*Catalogue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, irr.Wrap(err, "read catalogue %s failed", path)
+	}
+	cat := &Catalogue{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, cat)
+	} else {
+		err = yaml.Unmarshal(data, cat)
+	}
+	if err != nil {
+		return nil, irr.Wrap(err, "decode catalogue %s failed", path)
+	}
+	if err = cat.Normalize(); err != nil {
+		return nil, irr.Wrap(err, "catalogue %s", path)
+	}
+	return cat, nil
+}
+
+// Normalize validates the catalogue, fills the defaults of each code from
+// its range and sorts ranges and codes in ascending order.
+func (c *Catalogue) Normalize() error {
+	if c.Package == "" {
+		c.Package = "errcode"
+	}
+	if !identPattern.MatchString(c.Package) {
+		return irr.Wrap(ErrInvalidCatalogue, "invalid package name %q", c.Package)
+	}
+	if c.ConstPrefix == "" {
+		c.ConstPrefix = defaultConstPrefix
+	}
+
+	sort.SliceStable(c.Ranges, func(i, j int) bool { return c.Ranges[i].From < c.Ranges[j].From })
+	for i, r := range c.Ranges {
+		if r.Name == "" || r.From > r.To {
+			return irr.Wrap(ErrInvalidCatalogue, "invalid range %q [%d, %d]", r.Name, r.From, r.To)
+		}
+		if i > 0 && c.Ranges[i-1].To >= r.From {
+			return irr.Wrap(ErrInvalidCatalogue, "range %s overlaps with %s", r.Name, c.Ranges[i-1].Name)
+		}
+	}
+
+	sort.SliceStable(c.Codes, func(i, j int) bool { return c.Codes[i].Code < c.Codes[j].Code })
+	codes, names, consts := make(map[int64]string), make(map[string]int64), make(map[string]string)
+	for i := range c.Codes {
+		e := &c.Codes[i]
+		if e.Code == 0 {
+			return irr.Wrap(ErrInvalidCatalogue, "code of %s must not be 0", e.Name)
+		}
+		if !namePattern.MatchString(e.Name) {
+			return irr.Wrap(ErrInvalidCatalogue, "invalid name %q of code %d, UPPER_SNAKE_CASE is required", e.Name, e.Code)
+		}
+		if exist, ok := codes[e.Code]; ok {
+			return irr.Wrap(ErrInvalidCatalogue, "duplicate code %d of %s and %s", e.Code, exist, e.Name)
+		}
+		if exist, ok := names[e.Name]; ok {
+			return irr.Wrap(ErrInvalidCatalogue, "duplicate name %s of code %d and %d", e.Name, exist, e.Code)
+		}
+		codes[e.Code], names[e.Name] = e.Name, e.Code
+
+		if e.Const == "" {
+			e.Const = c.ConstPrefix + camelCase(e.Name)
+		}
+		if !identPattern.MatchString(e.Const) {
+			return irr.Wrap(ErrInvalidCatalogue, "invalid const name %q of code %d", e.Const, e.Code)
+		}
+		if exist, ok := consts[e.Const]; ok {
+			return irr.Wrap(ErrInvalidCatalogue, "duplicate const %s of %s and %s", e.Const, exist, e.Name)
+		}
+		consts[e.Const] = e.Name
+
+		if e.Severity == "" {
+			e.Severity = irc.SeverityError.String()
+		}
+		e.Severity = strings.ToLower(e.Severity)
+		if _, ok := severities[e.Severity]; !ok {
+			return irr.Wrap(ErrInvalidCatalogue, "unknown severity %q of code %d", e.Severity, e.Code)
+		}
+		if e.DocsURL == "" && c.DocsBaseURL != "" {
+			e.DocsURL = c.DocsBaseURL + e.Name
+		}
+
+		if len(c.Ranges) == 0 {
+			continue
+		}
+		r := c.rangeOf(e.Code)
+		if r == nil {
+			return irr.Wrap(ErrInvalidCatalogue, "code %d of %s is not in any range", e.Code, e.Name)
+		}
+		e.Range = r.Name
+		if e.Category == "" {
+			e.Category = r.Category
+		}
+		if e.HTTPStatus == 0 {
+			e.HTTPStatus = r.HTTPStatus
+		}
+	}
+	return nil
+}
+
+func (c *Catalogue) rangeOf(code int64) *Range {
+	for i := range c.Ranges {
+		if code >= c.Ranges[i].From && code <= c.Ranges[i].To {
+			return &c.Ranges[i]
+		}
+	}
+	return nil
+}
+
+// camelCase converts an UPPER_SNAKE_CASE name to CamelCase, e.g. USER_NOT_FOUND => UserNotFound
+func camelCase(name string) string {
+	sb := strings.Builder{}
+	for _, word := range strings.Split(name, "_") {
+		if word == "" {
+			continue
+		}
+		sb.WriteString(word[:1])
+		sb.WriteString(strings.ToLower(word[1:]))
+	}
+	return sb.String()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadCatalogue_YAML(t *testing.T) {
+	cat, err := LoadCatalogue("testdata/codes.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "errcode", cat.Package)
+
+	// ranges 与 codes 均按升序排列
+	assert.Equal(t, "system", cat.Ranges[0].Name)
+	assert.Equal(t, []int64{1001, 1002, 2001}, []int64{cat.Codes[0].Code, cat.Codes[1].Code, cat.Codes[2].Code})
+
+	db := cat.Codes[0]
+	assert.Equal(t, "ErrSystemDatabase", db.Const)
+	assert.Equal(t, "system", db.Range)
+	assert.Equal(t, "system", db.Category)
+	assert.Equal(t, 500, db.HTTPStatus)
+	assert.Equal(t, "critical", db.Severity)
+	assert.Equal(t, "https://docs.example.com/errors/SYSTEM_DATABASE", db.DocsURL)
+
+	network := cat.Codes[1]
+	assert.Equal(t, "ErrNetwork", network.Const)
+	assert.Equal(t, "error", network.Severity)
+	assert.Equal(t, "https://wiki.example.com/network", network.DocsURL)
+
+	// 显式指定的 http_status 优先于 range 的默认值
+	assert.Equal(t, 400, cat.Codes[2].HTTPStatus)
+	assert.Equal(t, "business", cat.Codes[2].Category)
+}
+
+func TestLoadCatalogue_JSON(t *testing.T) {
+	cat, err := LoadCatalogue("testdata/codes.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "apicode", cat.Package)
+	assert.Equal(t, 2, len(cat.Codes))
+	assert.Equal(t, "CodeApiBadRequest", cat.Codes[0].Const)
+	assert.Equal(t, "warning", cat.Codes[0].Severity)
+	assert.Equal(t, "", cat.Codes[0].Range)
+	assert.Equal(t, uint32(5), cat.Codes[1].GRPCCode)
+
+	_, err = LoadCatalogue("testdata/not_exist.yaml")
+	assert.Error(t, err)
+}
+
+func TestCatalogue_Normalize(t *testing.T) {
+	tests := []struct {
+		name string
+		cat  Catalogue
+		msg  string
+	}{
+		{"bad package", Catalogue{Package: "a-b"}, "invalid package name"},
+		{"zero code", Catalogue{Codes: []Entry{{Code: 0, Name: "A"}}}, "must not be 0"},
+		{"bad name", Catalogue{Codes: []Entry{{Code: 1, Name: "notFound"}}}, "UPPER_SNAKE_CASE"},
+		{"duplicate code", Catalogue{Codes: []Entry{{Code: 1, Name: "A"}, {Code: 1, Name: "B"}}}, "duplicate code 1"},
+		{"duplicate name", Catalogue{Codes: []Entry{{Code: 1, Name: "A"}, {Code: 2, Name: "A"}}}, "duplicate name A"},
+		{"duplicate const", Catalogue{Codes: []Entry{{Code: 1, Name: "A"}, {Code: 2, Name: "B", Const: "CodeA"}}}, "duplicate const CodeA"},
+		{"bad const", Catalogue{Codes: []Entry{{Code: 1, Name: "A", Const: "1A"}}}, "invalid const name"},
+		{"bad severity", Catalogue{Codes: []Entry{{Code: 1, Name: "A", Severity: "fatal"}}}, "unknown severity"},
+		{"bad range", Catalogue{Ranges: []Range{{Name: "r", From: 2, To: 1}}}, "invalid range"},
+		{"overlapped ranges", Catalogue{Ranges: []Range{{Name: "a", From: 1, To: 10}, {Name: "b", From: 10, To: 20}}}, "overlaps"},
+		{"out of range", Catalogue{Ranges: []Range{{Name: "a", From: 1, To: 10}}, Codes: []Entry{{Code: 11, Name: "A"}}}, "not in any range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cat.Normalize()
+			assert.True(t, errors.Is(err, ErrInvalidCatalogue), err)
+			assert.Contains(t, err.Error(), tt.msg)
+		})
+	}
+}
+
+func TestCamelCase(t *testing.T) {
+	assert.Equal(t, "UserNotFound", camelCase("USER_NOT_FOUND"))
+	assert.Equal(t, "Http2Error", camelCase("HTTP2__ERROR_"))
+	assert.Equal(t, "A", camelCase("A"))
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+
+	"github.com/khicago/irr"
+)
+
+type (
+	// manifest is the JSON manifest for the frontend, Go specific fields are dropped.
+	manifest struct {
+		Ranges []Range         `json:"ranges,omitempty"`
+		Codes  []manifestEntry `json:"codes"`
+	}
+
+	manifestEntry struct {
+		Code        int64  `json:"code"`
+		Name        string `json:"name"`
+		Message     string `json:"message,omitempty"`
+		Description string `json:"description,omitempty"`
+		Category    string `json:"category,omitempty"`
+		Range       string `json:"range,omitempty"`
+		HTTPStatus  int    `json:"http_status,omitempty"`
+		GRPCCode    uint32 `json:"grpc_code,omitempty"`
+		Retryable   bool   `json:"retryable"`
+		Severity    string `json:"severity"`
+		DocsURL     string `json:"docs_url,omitempty"`
+	}
+)
+
+const generatedHeader = "Code generated by irrgen. DO NOT EDIT."
+
+var severityIdents = map[string]string{
+	"info":     "irc.SeverityInfo",
+	"warning":  "irc.SeverityWarning",
+	"error":    "irc.SeverityError",
+	"critical": "irc.SeverityCritical",
+}
+
+// GenerateGo renders the code constants and their registration of a normalized catalogue.
+func GenerateGo(c *Catalogue) ([]byte, error) {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "// %s\n\npackage %s\n\n", generatedHeader, c.Package)
+	sb.WriteString("import \"github.com/khicago/irr/irc\"\n\n")
+
+	sb.WriteString("const (\n")
+	lastRange := ""
+	for i, e := range c.Codes {
+		if e.Range != lastRange || i == 0 {
+			if i > 0 {
+				sb.WriteRune('\n')
+			}
+			if r := c.rangeByName(e.Range); r != nil {
+				fmt.Fprintf(sb, "\t// %s [%d, %d]", r.Name, r.From, r.To)
+				if r.Description != "" {
+					fmt.Fprintf(sb, " %s", oneLine(r.Description))
+				}
+				sb.WriteString("\n\n")
+			}
+			lastRange = e.Range
+		}
+		fmt.Fprintf(sb, "\t// %s %s\n", e.Const, oneLine(firstNonEmpty(e.Description, e.Message, e.Name)))
+		fmt.Fprintf(sb, "\t%s irc.Code = %d\n", e.Const, e.Code)
+	}
+	sb.WriteString(")\n\n")
+
+	sb.WriteString("func init() {\n")
+	for _, e := range c.Codes {
+		fields := []string{"Code: " + e.Const, "Name: " + strconv.Quote(e.Name)}
+		if e.Message != "" {
+			fields = append(fields, "Message: "+strconv.Quote(e.Message))
+		}
+		if e.Category != "" {
+			fields = append(fields, "Category: "+strconv.Quote(e.Category))
+		}
+		if e.HTTPStatus != 0 {
+			fields = append(fields, "HTTPStatus: "+strconv.Itoa(e.HTTPStatus))
+		}
+		if e.GRPCCode != 0 {
+			fields = append(fields, "GRPCCode: "+strconv.FormatUint(uint64(e.GRPCCode), 10))
+		}
+		if e.Retryable {
+			fields = append(fields, "Retryable: true")
+		}
+		fields = append(fields, "Severity: "+severityIdents[e.Severity])
+		if e.DocsURL != "" {
+			fields = append(fields, "DocsURL: "+strconv.Quote(e.DocsURL))
+		}
+		fmt.Fprintf(sb, "\tirc.MustRegister(irc.Meta{\n\t\t%s,\n\t})\n", strings.Join(fields, ",\n\t\t"))
+	}
+	sb.WriteString("}\n")
+
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return nil, irr.Wrap(err, "format generated go source failed")
+	}
+	return src, nil
+}
+
+// GenerateMarkdown renders the reference page of a normalized catalogue, codes are grouped by range.
+func GenerateMarkdown(c *Catalogue) []byte {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "<!-- %s -->\n\n# Error Codes\n", generatedHeader)
+
+	lastRange := ""
+	for i, e := range c.Codes {
+		if e.Range != lastRange || i == 0 {
+			if r := c.rangeByName(e.Range); r != nil {
+				fmt.Fprintf(sb, "\n## %s (%d-%d)\n\n", r.Name, r.From, r.To)
+				if r.Description != "" {
+					fmt.Fprintf(sb, "%s\n\n", oneLine(r.Description))
+				}
+			} else {
+				sb.WriteString("\n## Codes\n\n")
+			}
+			sb.WriteString("| Code | Name | Message | Category | HTTP | gRPC | Retryable | Severity |\n")
+			sb.WriteString("| ---: | --- | --- | --- | ---: | ---: | :---: | --- |\n")
+			lastRange = e.Range
+		}
+
+		name := "`" + e.Name + "`"
+		if e.DocsURL != "" {
+			name = "[" + name + "](" + e.DocsURL + ")"
+		}
+		message := mdCell(e.Message)
+		if e.Description != "" {
+			message += "<br>" + mdCell(e.Description)
+		}
+		fmt.Fprintf(sb, "| %d | %s | %s | %s | %s | %s | %s | %s |\n",
+			e.Code, name, message, mdCell(e.Category), intCell(int64(e.HTTPStatus)), intCell(int64(e.GRPCCode)),
+			map[bool]string{true: "yes", false: "no"}[e.Retryable], e.Severity)
+	}
+	return []byte(sb.String())
+}
+
+// GenerateManifest renders the JSON manifest of a normalized catalogue.
+func GenerateManifest(c *Catalogue) ([]byte, error) {
+	m := manifest{Ranges: c.Ranges, Codes: make([]manifestEntry, 0, len(c.Codes))}
+	for _, e := range c.Codes {
+		m.Codes = append(m.Codes, manifestEntry{
+			Code:        e.Code,
+			Name:        e.Name,
+			Message:     e.Message,
+			Description: e.Description,
+			Category:    e.Category,
+			Range:       e.Range,
+			HTTPStatus:  e.HTTPStatus,
+			GRPCCode:    e.GRPCCode,
+			Retryable:   e.Retryable,
+			Severity:    e.Severity,
+			DocsURL:     e.DocsURL,
+		})
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, irr.Wrap(err, "marshal manifest failed")
+	}
+	return append(data, '\n'), nil
+}
+
+func (c *Catalogue) rangeByName(name string) *Range {
+	for i := range c.Ranges {
+		if c.Ranges[i].Name == name {
+			return &c.Ranges[i]
+		}
+	}
+	return nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func mdCell(s string) string {
+	return strings.ReplaceAll(oneLine(s), "|", `\|`)
+}
+
+func intCell(v int64) string {
+	if v == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(v, 10)
+}
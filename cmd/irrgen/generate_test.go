@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadTestCatalogue(t *testing.T) *Catalogue {
+	cat, err := LoadCatalogue("testdata/codes.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cat
+}
+
+func TestGenerateGo(t *testing.T) {
+	src, err := GenerateGo(loadTestCatalogue(t))
+	assert.NoError(t, err)
+
+	file, err := parser.ParseFile(token.NewFileSet(), "codes_gen.go", src, parser.ParseComments)
+	assert.NoError(t, err)
+	assert.Equal(t, "errcode", file.Name.Name)
+
+	code := string(src)
+	assert.True(t, strings.HasPrefix(code, "// Code generated by irrgen. DO NOT EDIT.\n"))
+	assert.Contains(t, code, "ErrSystemDatabase irc.Code = 1001")
+	assert.Contains(t, code, "ErrNetwork irc.Code = 1002")
+	assert.Contains(t, code, "// business [2000, 2999] Business logic errors")
+	assert.Contains(t, code, `Name:       "SYSTEM_DATABASE",`)
+	assert.Contains(t, code, "Severity:   irc.SeverityCritical,")
+	assert.Contains(t, code, "Retryable:  true,")
+	assert.Equal(t, 3, strings.Count(code, "irc.MustRegister("))
+
+	// 输出稳定，可以提交到仓库中
+	again, _ := GenerateGo(loadTestCatalogue(t))
+	assert.Equal(t, src, again)
+}
+
+func TestGenerateMarkdown(t *testing.T) {
+	md := string(GenerateMarkdown(loadTestCatalogue(t)))
+	assert.Contains(t, md, "## system (1000-1999)\n\nInfrastructure errors\n")
+	assert.Contains(t, md, "| 1001 | [`SYSTEM_DATABASE`](https://docs.example.com/errors/SYSTEM_DATABASE) | database unavailable<br>The database cannot be reached \\| retry later | system | 500 | 14 | yes | critical |")
+	assert.Contains(t, md, "| 2001 | [`BUSINESS_VALIDATION`](https://docs.example.com/errors/BUSINESS_VALIDATION) | invalid input | business | 400 | - | no | warning |")
+	assert.Less(t, strings.Index(md, "## system"), strings.Index(md, "## business"))
+}
+
+func TestGenerateManifest(t *testing.T) {
+	data, err := GenerateManifest(loadTestCatalogue(t))
+	assert.NoError(t, err)
+
+	m := manifest{}
+	assert.NoError(t, json.Unmarshal(data, &m))
+	assert.Equal(t, 2, len(m.Ranges))
+	assert.Equal(t, 3, len(m.Codes))
+	assert.Equal(t, manifestEntry{
+		Code:       2001,
+		Name:       "BUSINESS_VALIDATION",
+		Message:    "invalid input",
+		Category:   "business",
+		Range:      "business",
+		HTTPStatus: 400,
+		Severity:   "warning",
+		DocsURL:    "https://docs.example.com/errors/BUSINESS_VALIDATION",
+	}, m.Codes[2])
+	assert.NotContains(t, string(data), "const")
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	goFile, mdFile, jsonFile := filepath.Join(dir, "codes_gen.go"), filepath.Join(dir, "CODES.md"), filepath.Join(dir, "codes.json")
+	err := run([]string{"-in", "testdata/codes.json", "-pkg", "other", "-go", goFile, "-md", mdFile, "-json", jsonFile})
+	assert.NoError(t, err)
+
+	src, err := os.ReadFile(goFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), "package other\n")
+	assert.Contains(t, string(src), "CodeApiNotFound irc.Code = 3002")
+
+	md, err := os.ReadFile(mdFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(md), "## Codes\n")
+
+	_, err = os.Stat(jsonFile)
+	assert.NoError(t, err)
+
+	assert.Error(t, run([]string{}))
+	assert.Error(t, run([]string{"-in", "testdata/codes.json", "-pkg", "bad-pkg"}))
+}
@@ -0,0 +1,14 @@
+module github.com/khicago/irr/cmd/irrgen
+
+go 1.21
+
+require (
+	github.com/khicago/irr v0.0.0-20261016131829-aecf1618ce3a
+	github.com/stretchr/testify v1.7.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
@@ -0,0 +1,102 @@
+// Command irrgen generates error code constants, their irc registration, a
+// Markdown reference page and a JSON manifest from a YAML or JSON catalogue.
+//
+// It's a separate module, so that the core irr module does not depend on the
+// YAML parser. It works fully offline and is meant to be run by go generate:
+//
+//	//go:generate go run github.com/khicago/irr/cmd/irrgen@latest -in codes.yaml -go codes_gen.go -md CODES.md -json codes.json
+//
+// A catalogue looks like:
+//
+//	package: errcode
+//	const_prefix: Err
+//	docs_base_url: https://docs.example.com/errors/
+//	ranges:
+//	  - name: system
+//	    from: 1000
+//	    to: 1999
+//	    category: system
+//	    http_status: 500
+//	codes:
+//	  - code: 1001
+//	    name: SYSTEM_DATABASE
+//	    message: database unavailable
+//	    retryable: true
+//	    severity: critical
+//
+// Each output is optional, only the given ones are written.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/khicago/irr"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "irrgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("irrgen", flag.ContinueOnError)
+	in := fs.String("in", "", "the catalogue file, .json for JSON and YAML otherwise (required)")
+	goOut := fs.String("go", "", "the generated Go file")
+	mdOut := fs.String("md", "", "the generated Markdown reference page")
+	jsonOut := fs.String("json", "", "the generated JSON manifest")
+	pkg := fs.String("pkg", "", "override the package name of the catalogue")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" {
+		fs.Usage()
+		return irr.Error("-in is required")
+	}
+
+	cat, err := LoadCatalogue(*in)
+	if err != nil {
+		return err
+	}
+	if *pkg != "" {
+		cat.Package = *pkg
+		if err = cat.Normalize(); err != nil {
+			return err
+		}
+	}
+
+	if *goOut != "" {
+		src, err := GenerateGo(cat)
+		if err != nil {
+			return err
+		}
+		if err = writeFile(*goOut, src); err != nil {
+			return err
+		}
+	}
+	if *mdOut != "" {
+		if err = writeFile(*mdOut, GenerateMarkdown(cat)); err != nil {
+			return err
+		}
+	}
+	if *jsonOut != "" {
+		data, err := GenerateManifest(cat)
+		if err != nil {
+			return err
+		}
+		if err = writeFile(*jsonOut, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return irr.Wrap(err, "write %s failed", path)
+	}
+	return nil
+}
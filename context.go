@@ -3,6 +3,8 @@ package irr
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -53,6 +55,7 @@ func TraceWithContext(ctx context.Context, formatOrMsg string, args ...any) Cont
 	recordErrorWithTrace()
 	err := newBasicIrr(formatOrMsg, args...)
 	err.Trace = createTraceInfo(1, nil)
+	recordHotspot(err)
 	return &ContextualIrr{
 		BasicIrr: err,
 		ctx:      ctx,
@@ -67,6 +70,7 @@ func TrackWithContext(ctx context.Context, innerErr error, formatOrMsg string, a
 	err := newBasicIrr(formatOrMsg, args...)
 	err.inner = innerErr
 	err.Trace = createTraceInfo(1, innerErr)
+	recordHotspot(err)
 	return &ContextualIrr{
 		BasicIrr: err,
 		ctx:      ctx,
@@ -91,14 +95,15 @@ func (ce *ContextualIrr) WithContext(ctx context.Context) ContextualError {
 
 // WithDeadline 设置截止时间
 func (ce *ContextualIrr) WithDeadline(deadline time.Time) ContextualError {
-	ctx, _ := context.WithDeadline(ce.Context(), deadline)
+	ctx, cancel := context.WithDeadline(ce.Context(), deadline)
+	// 上下文只用于描述错误，不会提前取消，到达截止时间后由其自身的定时器释放
+	_ = cancel
 	return ce.WithContext(ctx)
 }
 
 // WithTimeout 设置超时时间
 func (ce *ContextualIrr) WithTimeout(timeout time.Duration) ContextualError {
-	ctx, _ := context.WithTimeout(ce.Context(), timeout)
-	return ce.WithContext(ctx)
+	return ce.WithDeadline(time.Now().Add(timeout))
 }
 
 // WithValue 添加键值对
@@ -123,6 +128,28 @@ func (ce *ContextualIrr) ToString(printTrace bool, split string) string {
 	return result
 }
 
+// Format 实现 fmt.Formatter，与 BasicIrr 相同，但输出包含上下文信息
+func (ce *ContextualIrr) Format(s fmt.State, verb rune) {
+	formatError(s, verb, ce.ToString, ce.GoString)
+}
+
+// GoString 实现 fmt.GoStringer，用于 %#v
+func (ce *ContextualIrr) GoString() string {
+	sb := strings.Builder{}
+	sb.WriteString("&irr.ContextualIrr{")
+	ce.BasicIrr.writeGoSyntaxTo(&sb)
+	if ce.ctx != nil {
+		if deadline, ok := ce.ctx.Deadline(); ok {
+			fmt.Fprintf(&sb, ", Deadline:%q", deadline.Format(time.RFC3339))
+		}
+		if ce.ctx.Err() != nil {
+			fmt.Fprintf(&sb, ", CtxErr:%q", ce.ctx.Err().Error())
+		}
+	}
+	sb.WriteRune('}')
+	return sb.String()
+}
+
 // IsContextError 检查是否为上下文相关错误
 func IsContextError(err error) bool {
 	if err == nil {
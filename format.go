@@ -0,0 +1,93 @@
+package irr
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	_ fmt.Formatter = (*BasicIrr)(nil)
+	_ fmt.Formatter = (*MultiIrr)(nil)
+)
+
+// Format
+// the implementation of fmt.Formatter
+//
+//	%s, %v  the compact chain, the same as Error()
+//	%+v     the multi-line chain with traces and tags, the same as ToString(true, "\n")
+//	%#v     a Go-syntax-like dump of code, msg, tags, trace and inner errors
+//	%q      the quoted compact chain
+func (ir *BasicIrr) Format(s fmt.State, verb rune) {
+	formatError(s, verb, ir.ToString, ir.GoString)
+}
+
+// GoString
+// the implementation of fmt.GoStringer, it's used by %#v
+func (ir *BasicIrr) GoString() string {
+	sb := strings.Builder{}
+	sb.WriteString("&irr.BasicIrr{")
+	ir.writeGoSyntaxTo(&sb)
+	sb.WriteRune('}')
+	return sb.String()
+}
+
+func (ir *BasicIrr) writeGoSyntaxTo(sb *strings.Builder) {
+	fmt.Fprintf(sb, "Code:%d, Msg:%q", ir.Code, ir.Msg)
+	if ir.publicMsg != "" {
+		fmt.Fprintf(sb, ", Public:%q", ir.publicMsg)
+	}
+	if ir.retryClass != ClassUnknown {
+		fmt.Fprintf(sb, ", Retry:%s", ir.retryClass)
+	}
+	if ir.retryAfter != 0 {
+		fmt.Fprintf(sb, ", RetryAfter:%s", ir.retryAfter)
+	}
+	if tags := redactTags(ir.AllTags()); len(tags) > 0 {
+		fmt.Fprintf(sb, ", Tags:%#v", tags)
+	}
+	if attrs := ir.attrs.Load(); attrs != nil && len(*attrs) > 0 {
+		fmt.Fprintf(sb, ", Attrs:%v", redactAttrs(*attrs))
+	}
+	if ir.Trace != nil {
+		fmt.Fprintf(sb, ", Trace:%q", ir.Trace.String())
+	}
+	if ir.inner != nil {
+		fmt.Fprintf(sb, ", Inner:%#v", ir.inner)
+	}
+}
+
+// Format
+// the implementation of fmt.Formatter, verbs are the same as BasicIrr.Format
+func (m *MultiIrr) Format(s fmt.State, verb rune) {
+	formatError(s, verb, m.ToString, m.GoString)
+}
+
+// GoString
+// the implementation of fmt.GoStringer, it's used by %#v
+func (m *MultiIrr) GoString() string {
+	sb := strings.Builder{}
+	sb.WriteString("&irr.MultiIrr{Errs:[]error{")
+	for i, err := range m.errs {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%#v", err)
+	}
+	sb.WriteString("}}")
+	return sb.String()
+}
+
+// formatError 按照 verb 渲染错误，%s %v %q 会保留宽度等格式标记
+func formatError(s fmt.State, verb rune, toString func(printTrace bool, split string) string, goString func() string) {
+	switch {
+	case verb == 'v' && s.Flag('#'):
+		_, _ = io.WriteString(s, goString())
+	case verb == 'v' && s.Flag('+'):
+		_, _ = io.WriteString(s, toString(true, "\n"))
+	case verb == 'v', verb == 's', verb == 'q':
+		_, _ = fmt.Fprintf(s, fmt.FormatString(s, verb), toString(false, ", "))
+	default:
+		_, _ = fmt.Fprintf(s, "%%!%c(%s)", verb, toString(false, ", "))
+	}
+}
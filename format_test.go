@@ -0,0 +1,70 @@
+package irr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatTraceAndTags(t *testing.T) {
+	inner := Trace("inner %d", 1)
+	err := Track(inner, "outer")
+	err.SetTag("user", "u1")
+
+	plus := fmt.Sprintf("%+v", err)
+	assert.Equal(t, err.ToString(true, "\n"), plus)
+	lines := strings.Split(plus, "\n")
+	assert.Equal(t, 2, len(lines), plus)
+	assert.True(t, strings.HasPrefix(lines[0], "outer[user:u1]"), plus)
+	assert.Contains(t, lines[0], "irr.TestFormatTraceAndTags@")
+	assert.Contains(t, lines[1], "/format_test.go:")
+
+	assert.Equal(t, err.Error(), fmt.Sprintf("%v", err))
+	assert.NotContains(t, fmt.Sprintf("%v", err), "format_test.go")
+}
+
+func TestFormatGoSyntax(t *testing.T) {
+	err := Wrap(errors.New("std"), "outer %s", "msg").SetCode(404)
+	err.SetTag("k", "v")
+
+	str := fmt.Sprintf("%#v", err)
+	assert.Equal(t, `&irr.BasicIrr{Code:404, Msg:"outer msg", Tags:[]irr.Tag{irr.Tag{Key:"k", Value:"v"}}, Inner:&errors.errorString{s:"std"}}`, str)
+	assert.Equal(t, str, err.(*BasicIrr).GoString())
+
+	traced := Track(ErrorC(1, "inner"), "traced")
+	str = fmt.Sprintf("%#v", traced)
+	assert.True(t, strings.HasPrefix(str, `&irr.BasicIrr{Code:0, Msg:"traced", Trace:"irr.TestFormatGoSyntax@`), str)
+	assert.True(t, strings.HasSuffix(str, `Inner:&irr.BasicIrr{Code:1, Msg:"inner"}}`), str)
+}
+
+func TestFormatWidthAndQuote(t *testing.T) {
+	err := Error("abc")
+	assert.Equal(t, "  abc", fmt.Sprintf("%5s", err))
+	assert.Equal(t, "abc  ", fmt.Sprintf("%-5v", err))
+	assert.Equal(t, `"abc"`, fmt.Sprintf("%q", err))
+}
+
+func TestFormatMultiIrr(t *testing.T) {
+	err := Join(ErrorC(400, "a"), errors.New("b"))
+	assert.Equal(t, err.Error(), fmt.Sprintf("%v", err))
+	assert.Equal(t, "{code(400), a; b}", fmt.Sprintf("%s", err))
+	assert.Equal(t, `&irr.MultiIrr{Errs:[]error{&irr.BasicIrr{Code:400, Msg:"a"}, &errors.errorString{s:"b"}}}`, fmt.Sprintf("%#v", err))
+
+	traced := Join(Trace("traced"), errors.New("b"))
+	assert.Contains(t, fmt.Sprintf("%+v", traced), "/format_test.go:")
+}
+
+func TestFormatContextualIrr(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC))
+	defer cancel()
+	err := ErrorWithContext(ctx, "with ctx")
+
+	assert.Equal(t, err.ToString(false, ", "), fmt.Sprintf("%v", err))
+	assert.Contains(t, fmt.Sprintf("%v", err), "[deadline:")
+	assert.Contains(t, fmt.Sprintf("%#v", err), `&irr.ContextualIrr{Code:0, Msg:"with ctx", Deadline:"2030-01-02T`)
+}
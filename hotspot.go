@@ -0,0 +1,135 @@
+package irr
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// Hotspot 一个产生错误的调用位置，见 Hotspots
+	Hotspot struct {
+		FuncName string   `json:"func"`
+		FileName string   `json:"file"`
+		Line     int      `json:"line"`
+		Count    int64    `json:"count"`
+		Examples []string `json:"examples"` // 至多 MaxHotspotExamples 条不同的消息，敏感参数已脱敏
+	}
+
+	hotspotKey struct {
+		file string
+		line int
+	}
+
+	hotspotEntry struct {
+		funcName string
+		count    atomic.Int64
+
+		mu       sync.Mutex
+		examples []string
+		full     atomic.Bool
+	}
+)
+
+// MaxHotspotExamples 每个调用位置保留的示例消息数量
+const MaxHotspotExamples = 3
+
+var (
+	hotspotEnabled atomic.Bool
+	// hotspots 保存 hotspotKey 到 *hotspotEntry 的映射
+	hotspots sync.Map
+)
+
+// EnableHotspots 开启或关闭按调用位置的错误统计，默认关闭
+// 开启后 Trace/Track 系列方法记录的调用位置（与 GetTraceInfo 相同）会被计数，
+// 不带堆栈跟踪的错误没有调用位置，不会被统计
+func EnableHotspots(enabled bool) {
+	hotspotEnabled.Store(enabled)
+}
+
+// HotspotsEnabled 返回是否开启了按调用位置的错误统计
+func HotspotsEnabled() bool {
+	return hotspotEnabled.Load()
+}
+
+// Hotspots 返回错误数量最多的 n 个调用位置，按数量降序，数量相同时按文件与行号升序
+// n 不大于 0 时返回全部
+func Hotspots(n int) []Hotspot {
+	var result []Hotspot
+	hotspots.Range(func(key, val any) bool {
+		k, entry := key.(hotspotKey), val.(*hotspotEntry)
+		entry.mu.Lock()
+		examples := append([]string(nil), entry.examples...)
+		entry.mu.Unlock()
+		result = append(result, Hotspot{
+			FuncName: entry.funcName,
+			FileName: k.file,
+			Line:     k.line,
+			Count:    entry.count.Load(),
+			Examples: examples,
+		})
+		return true
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		if result[i].FileName != result[j].FileName {
+			return result[i].FileName < result[j].FileName
+		}
+		return result[i].Line < result[j].Line
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// ResetHotspots 清空按调用位置的错误统计
+func ResetHotspots() {
+	hotspots.Range(func(key, _ any) bool {
+		hotspots.Delete(key)
+		return true
+	})
+}
+
+// String 实现 fmt.Stringer，格式与调用位置的输出一致
+func (h Hotspot) String() string {
+	return h.FuncName + "@" + h.FileName + ":" + strconv.Itoa(h.Line) + " x" + strconv.FormatInt(h.Count, 10)
+}
+
+// recordHotspot 在开启统计时记录 err 的调用位置
+func recordHotspot(err *BasicIrr) {
+	if !hotspotEnabled.Load() || err.Trace == nil {
+		return
+	}
+	key := hotspotKey{file: err.Trace.FileName, line: err.Trace.Line}
+	val, ok := hotspots.Load(key)
+	if !ok {
+		val, _ = hotspots.LoadOrStore(key, &hotspotEntry{funcName: err.Trace.FuncName})
+	}
+	entry := val.(*hotspotEntry)
+	entry.count.Add(1)
+	if !entry.full.Load() {
+		entry.addExample(err.Msg)
+	}
+}
+
+func (e *hotspotEntry) addExample(msg string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, example := range e.examples {
+		if example == msg {
+			return
+		}
+	}
+	if len(e.examples) < MaxHotspotExamples {
+		e.examples = append(e.examples, msg)
+	}
+	if len(e.examples) >= MaxHotspotExamples {
+		e.full.Store(true)
+	}
+}
@@ -0,0 +1,101 @@
+package irr
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hotspotNoisy(i int) IRR {
+	return Trace("noisy %d", i%5)
+}
+
+func hotspotQuiet(inner error) IRR {
+	return Track(inner, "quiet")
+}
+
+func TestHotspots(t *testing.T) {
+	EnableHotspots(true)
+	defer EnableHotspots(false)
+	ResetHotspots()
+	defer ResetHotspots()
+	assert.True(t, HotspotsEnabled())
+
+	for i := 0; i < 10; i++ {
+		_ = hotspotNoisy(i)
+	}
+	_ = hotspotQuiet(Error("no trace"))
+	_ = hotspotQuiet(Error("no trace"))
+	_ = TraceWithContext(context.Background(), "with context")
+	// 不带堆栈跟踪的错误没有调用位置
+	_ = Wrap(Error("plain"), "plain")
+
+	report := Hotspots(0)
+	assert.Len(t, report, 3)
+
+	noisy := report[0]
+	assert.Equal(t, int64(10), noisy.Count)
+	assert.True(t, strings.HasSuffix(noisy.FuncName, ".hotspotNoisy"), noisy.FuncName)
+	assert.True(t, strings.HasSuffix(noisy.FileName, "hotspot_test.go"), noisy.FileName)
+	assert.Greater(t, noisy.Line, 0)
+	// 示例消息去重且有数量上限
+	assert.Equal(t, []string{"noisy 0", "noisy 1", "noisy 2"}, noisy.Examples)
+	assert.Equal(t, noisy.FuncName+"@"+noisy.FileName+":"+strconv.Itoa(noisy.Line)+" x10", noisy.String())
+
+	assert.Equal(t, int64(2), report[1].Count)
+	assert.True(t, strings.HasSuffix(report[1].FuncName, ".hotspotQuiet"))
+	assert.Equal(t, []string{"quiet"}, report[1].Examples)
+	assert.Equal(t, int64(1), report[2].Count)
+
+	assert.Equal(t, report[:1], Hotspots(1))
+
+	ResetHotspots()
+	assert.Empty(t, Hotspots(0))
+}
+
+func TestHotspots_Disabled(t *testing.T) {
+	ResetHotspots()
+	assert.False(t, HotspotsEnabled())
+	_ = Trace("not tracked")
+	assert.Empty(t, Hotspots(0))
+}
+
+func TestHotspots_Redacted(t *testing.T) {
+	EnableHotspots(true)
+	defer EnableHotspots(false)
+	ResetHotspots()
+	defer ResetHotspots()
+
+	_ = Trace("login %s failed", Secret("hunter2"))
+	report := Hotspots(0)
+	assert.Len(t, report, 1)
+	assert.Equal(t, []string{"login *** failed"}, report[0].Examples)
+}
+
+func TestHotspots_Concurrent(t *testing.T) {
+	EnableHotspots(true)
+	defer EnableHotspots(false)
+	ResetHotspots()
+	defer ResetHotspots()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = hotspotNoisy(j)
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := Hotspots(0)
+	assert.Len(t, report, 1)
+	assert.Equal(t, int64(800), report[0].Count)
+	assert.Len(t, report[0].Examples, MaxHotspotExamples)
+}
@@ -0,0 +1,293 @@
+// Package i18n localizes error messages by code.
+//
+// A Catalog holds message templates per language, keyed by the decimal code
+// (e.g. "404"), the registered code name (e.g. "USER_NOT_FOUND") or a free
+// form message ID (e.g. "user.not_found"). Codes don't need to be registered,
+// plain irc.Code values work as they are.
+//
+// Templates refer to named parameters as {name}, the parameters are taken from
+// the tags of the error chain, the nearest value wins:
+//
+//	{"404": "User {user} was not found"}
+//
+// Catalogs are loaded from JSON or TOML files, typically embedded with go:embed,
+// one file per language named after the language tag (en.json, zh-CN.toml).
+// Nested objects and TOML tables are flattened with ".", so {"user": {"not_found": "..."}}
+// defines the message ID "user.not_found".
+//
+// It's a separate module, so that the core irr module does not depend on the TOML parser.
+package i18n
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+)
+
+// Catalog holds the message templates of each language, it's safe for concurrent use.
+type Catalog struct {
+	mu          sync.RWMutex
+	defaultLang string
+	messages    map[string]map[string]string
+}
+
+var (
+	// DefaultCatalog is used by the package level functions, its default language is "en".
+	DefaultCatalog = NewCatalog("en")
+
+	// ErrInvalidCatalog means a catalog file can't be parsed.
+	ErrInvalidCatalog = errors.New("invalid message catalog")
+)
+
+// NewCatalog creates an empty catalog, defaultLang is the last language tried
+// when none of the requested languages has the message.
+func NewCatalog(defaultLang string) *Catalog {
+	return &Catalog{
+		defaultLang: normalizeLang(defaultLang),
+		messages:    make(map[string]map[string]string),
+	}
+}
+
+// DefaultLang returns the default language of the catalog.
+func (c *Catalog) DefaultLang() string {
+	return c.defaultLang
+}
+
+// Add adds the templates of a language, existing keys are replaced.
+func (c *Catalog) Add(lang string, messages map[string]string) {
+	lang = normalizeLang(lang)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := c.messages[lang]
+	if m == nil {
+		m = make(map[string]string, len(messages))
+		c.messages[lang] = m
+	}
+	for key, template := range messages {
+		m[key] = template
+	}
+}
+
+// AddCode adds the template of a code.
+func (c *Catalog) AddCode(lang string, code irc.Code, template string) {
+	c.Add(lang, map[string]string{codeKey(code): template})
+}
+
+// LoadJSON adds the templates of a language from a JSON object.
+func (c *Catalog) LoadJSON(lang string, data []byte) error {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return irr.Wrap(ErrInvalidCatalog, "parse json of %s, %v", lang, err)
+	}
+	return c.load(lang, raw)
+}
+
+// LoadTOML adds the templates of a language from a TOML document.
+func (c *Catalog) LoadTOML(lang string, data []byte) error {
+	var raw map[string]any
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return irr.Wrap(ErrInvalidCatalog, "parse toml of %s, %v", lang, err)
+	}
+	return c.load(lang, raw)
+}
+
+// LoadFS loads the files matching the patterns (see fs.Glob) from fsys, such as
+// an embed.FS. The language is the file name without the extension, and the
+// format is chosen by the extension, .json or .toml, other files are ignored.
+func (c *Catalog) LoadFS(fsys fs.FS, patterns ...string) error {
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return irr.Wrap(err, "glob %s", pattern)
+		}
+		for _, name := range names {
+			ext := path.Ext(name)
+			var load func(lang string, data []byte) error
+			switch strings.ToLower(ext) {
+			case ".json":
+				load = c.LoadJSON
+			case ".toml":
+				load = c.LoadTOML
+			default:
+				continue
+			}
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return irr.Wrap(err, "read %s", name)
+			}
+			if err = load(strings.TrimSuffix(path.Base(name), ext), data); err != nil {
+				return irr.Wrap(err, "load %s", name)
+			}
+		}
+	}
+	return nil
+}
+
+// Languages returns the languages of the catalog, sorted.
+func (c *Catalog) Languages() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	langs := make([]string, 0, len(c.messages))
+	for lang := range c.messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Lookup returns the template of the key in the best matching language, see Fallbacks.
+func (c *Catalog) Lookup(lang, key string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, candidate := range c.fallbacks(lang) {
+		if template, ok := c.messages[candidate][key]; ok {
+			return template, true
+		}
+	}
+	return "", false
+}
+
+// LookupCode returns the template of the code, the decimal code is tried first,
+// then the registered name of the code.
+func (c *Catalog) LookupCode(lang string, code irc.Code) (string, bool) {
+	if template, ok := c.Lookup(lang, codeKey(code)); ok {
+		return template, true
+	}
+	if meta, ok := code.Meta(); ok {
+		return c.Lookup(lang, meta.Name)
+	}
+	return "", false
+}
+
+// Fallbacks returns the languages tried for lang in order: lang itself, its
+// parents obtained by dropping subtags (zh-Hant-TW, zh-Hant, zh), and the
+// default language. lang can be a comma separated list, such as the value of
+// an Accept-Language header: the languages are tried by their quality values,
+// highest first, and the ones with q=0 are left out.
+func (c *Catalog) Fallbacks(lang string) []string {
+	return c.fallbacks(lang)
+}
+
+func (c *Catalog) fallbacks(lang string) []string {
+	var result []string
+	add := func(lang string) {
+		for _, exist := range result {
+			if exist == lang {
+				return
+			}
+		}
+		result = append(result, lang)
+	}
+	for _, tag := range acceptedLangs(lang) {
+		for tag != "" && tag != "*" {
+			add(tag)
+			i := strings.LastIndexByte(tag, '-')
+			if i < 0 {
+				break
+			}
+			tag = tag[:i]
+		}
+	}
+	if c.defaultLang != "" {
+		add(c.defaultLang)
+	}
+	return result
+}
+
+func (c *Catalog) load(lang string, raw map[string]any) error {
+	messages := make(map[string]string, len(raw))
+	if err := flatten(messages, "", raw); err != nil {
+		return irr.Wrap(err, "load %s", lang)
+	}
+	c.Add(lang, messages)
+	return nil
+}
+
+// flatten joins the keys of nested objects with ".", values must be strings.
+func flatten(dst map[string]string, prefix string, raw map[string]any) error {
+	for key, val := range raw {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := val.(type) {
+		case string:
+			dst[key] = v
+		case map[string]any:
+			if err := flatten(dst, key, v); err != nil {
+				return err
+			}
+		default:
+			return irr.Wrap(ErrInvalidCatalog, "value of %s is %T, want a string", key, val)
+		}
+	}
+	return nil
+}
+
+// acceptedLangs parses a comma separated list of languages with optional quality
+// values, and returns the normalized languages sorted by quality, highest first.
+// Languages of the same quality keep their order, invalid or zero qualities are dropped.
+func acceptedLangs(list string) []string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(list, ",") {
+		q := 1.0
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			var ok bool
+			if q, ok = qualityOf(part[i+1:]); !ok {
+				continue
+			}
+			part = part[:i]
+		}
+		if q > 0 {
+			langs = append(langs, weighted{lang: normalizeLang(part), q: q})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+	result := make([]string, 0, len(langs))
+	for _, l := range langs {
+		result = append(result, l.lang)
+	}
+	return result
+}
+
+// qualityOf returns the q parameter of the parameters of a language, 1 when there is none.
+func qualityOf(params string) (float64, bool) {
+	for _, param := range strings.Split(params, ";") {
+		key, val, found := strings.Cut(strings.TrimSpace(param), "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(key), "q") {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil || q < 0 || q > 1 {
+			return 0, false
+		}
+		return q, true
+	}
+	return 1, true
+}
+
+// normalizeLang lowercases the language tag and replaces "_" with "-".
+func normalizeLang(lang string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+}
+
+func codeKey(code irc.Code) string {
+	return strconv.FormatInt(int64(code), 10)
+}
@@ -0,0 +1,96 @@
+package i18n
+
+import (
+	"embed"
+	"testing"
+	"testing/fstest"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed testdata/locales
+var testLocales embed.FS
+
+const (
+	testCodeUserNotFound irc.Code = 94001
+	testCodeQuota        irc.Code = 94002
+	testCodeRegistered   irc.Code = 94003
+)
+
+func init() {
+	irc.MustRegister(irc.Meta{Code: testCodeQuota, Name: "TEST_I18N_QUOTA"})
+	irc.MustRegister(irc.Meta{Code: testCodeRegistered, Name: "TEST_I18N_REGISTERED", Message: "registered message"})
+}
+
+func newTestCatalog(t *testing.T) *Catalog {
+	c := NewCatalog("en")
+	if err := c.LoadFS(testLocales, "testdata/locales/*"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCatalog_LoadFS(t *testing.T) {
+	c := newTestCatalog(t)
+	assert.Equal(t, []string{"en", "zh", "zh-tw"}, c.Languages())
+	assert.Equal(t, "en", c.DefaultLang())
+
+	template, ok := c.Lookup("zh", "order.locked")
+	assert.True(t, ok)
+	assert.Equal(t, "订单 {order} 已锁定", template)
+
+	// 未注册的错误码直接按数值查找，已注册的错误码也可以按名称查找
+	template, ok = c.LookupCode("zh-TW", testCodeUserNotFound)
+	assert.True(t, ok)
+	assert.Equal(t, "找不到使用者 {user}", template)
+	template, ok = c.LookupCode("en", testCodeQuota)
+	assert.True(t, ok)
+	assert.Equal(t, "Quota exceeded, try again in {retry_after}", template)
+	_, ok = c.LookupCode("en", testCodeRegistered)
+	assert.False(t, ok)
+}
+
+func TestCatalog_LoadErrors(t *testing.T) {
+	c := NewCatalog("en")
+	assert.ErrorIs(t, c.LoadJSON("en", []byte(`{"a": 1}`)), ErrInvalidCatalog)
+	assert.ErrorIs(t, c.LoadJSON("en", []byte(`{`)), ErrInvalidCatalog)
+	assert.ErrorIs(t, c.LoadTOML("en", []byte(`a = `)), ErrInvalidCatalog)
+
+	fsys := fstest.MapFS{"locales/fr.json": {Data: []byte(`[]`)}}
+	assert.ErrorIs(t, c.LoadFS(fsys, "locales/*.json"), ErrInvalidCatalog)
+	assert.Error(t, c.LoadFS(fsys, "["))
+	assert.Empty(t, c.Languages())
+}
+
+func TestCatalog_Fallbacks(t *testing.T) {
+	c := NewCatalog("en_US")
+	assert.Equal(t, []string{"zh-hant-tw", "zh-hant", "zh", "en-us"}, c.Fallbacks("zh_Hant_TW"))
+	assert.Equal(t, []string{"fr-ch", "fr", "en", "de", "en-us"}, c.Fallbacks("fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5"))
+	// 按 q 值排序，q=0 与非法的 q 值被忽略
+	assert.Equal(t, []string{"en", "de", "en-us"}, c.Fallbacks("de;q=0.1, en;q=0.9"))
+	assert.Equal(t, []string{"ja", "de", "en-us"}, c.Fallbacks("fr;q=0, de;q=0.5, it;q=abc, ja"))
+	assert.Equal(t, []string{"de", "fr", "en-us"}, c.Fallbacks("fr; Q=0.2, de;level=1"))
+	assert.Equal(t, []string{"en-us"}, c.Fallbacks(""))
+	assert.Empty(t, NewCatalog("").Fallbacks(""))
+}
+
+func TestCatalog_Add(t *testing.T) {
+	c := NewCatalog("en")
+	c.AddCode("en", 404, "Not found")
+	c.Add("EN", map[string]string{"x": "X"})
+	c.AddCode("en", 404, "Nothing here")
+
+	template, _ := c.LookupCode("en-GB", 404)
+	assert.Equal(t, "Nothing here", template)
+	template, _ = c.Lookup("en", "x")
+	assert.Equal(t, "X", template)
+	_, ok := c.Lookup("en", "y")
+	assert.False(t, ok)
+}
+
+func TestWithMessageID(t *testing.T) {
+	err := WithMessageID(irr.Error("locked"), "order.locked")
+	assert.Equal(t, []string{"order.locked"}, err.GetTag(TagMessageID))
+}
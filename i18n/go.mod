@@ -0,0 +1,15 @@
+module github.com/khicago/irr/i18n
+
+go 1.21
+
+require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/khicago/irr v0.0.0-20261016145238-866cf1527684
+	github.com/stretchr/testify v1.7.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
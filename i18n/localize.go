@@ -0,0 +1,112 @@
+package i18n
+
+import (
+	"strings"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+)
+
+// TagMessageID is the tag key of the message ID of a layer, see WithMessageID.
+const TagMessageID = "i18n.id"
+
+// WithMessageID sets the message ID of err, the template of the ID takes
+// precedence over the template of the code of the same layer.
+func WithMessageID(err irr.IRR, id string) irr.IRR {
+	err.SetTag(TagMessageID, id)
+	return err
+}
+
+// Localize renders the localized message of the chain in the best matching
+// language (see Catalog.Fallbacks). The chain is walked from the outermost
+// layer, the first layer whose message ID or code has a template wins.
+// When no template is found, the nearest public message (irr.PublicMessage)
+// is returned, then the registered message of the nearest code, or "".
+func (c *Catalog) Localize(err error, lang string) string {
+	if err == nil {
+		return ""
+	}
+	if template, ok := c.find(err, lang); ok {
+		return Render(template, paramsOf(err))
+	}
+	if public := irr.PublicMessage(err); public != "" {
+		return public
+	}
+	if meta, ok := irc.Code(irr.NearestCodeOf(err)).Meta(); ok {
+		return meta.Message
+	}
+	return ""
+}
+
+// Localize renders the localized message of the chain with the DefaultCatalog.
+func Localize(err error, lang string) string {
+	return DefaultCatalog.Localize(err, lang)
+}
+
+// Render replaces the {name} placeholders of the template with params,
+// placeholders without a param are kept as they are.
+func Render(template string, params map[string]string) string {
+	if len(params) == 0 || !strings.Contains(template, "{") {
+		return template
+	}
+	sb := strings.Builder{}
+	sb.Grow(len(template))
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		sb.WriteString(template[:start])
+		if val, ok := params[template[start+1:end]]; ok {
+			sb.WriteString(val)
+		} else {
+			sb.WriteString(template[start : end+1])
+		}
+		template = template[end+1:]
+	}
+	sb.WriteString(template)
+	return sb.String()
+}
+
+func (c *Catalog) find(err error, lang string) (template string, found bool) {
+	irr.Walk(err, func(err error) bool {
+		if tagger, ok := err.(irr.ITagger); ok {
+			if ids := tagger.GetTag(TagMessageID); len(ids) > 0 {
+				if template, found = c.Lookup(lang, ids[len(ids)-1]); found {
+					return false
+				}
+			}
+		}
+		if coder, ok := err.(interface{ CurrentCode() int64 }); ok && coder.CurrentCode() != 0 {
+			if template, found = c.LookupCode(lang, irc.Code(coder.CurrentCode())); found {
+				return false
+			}
+		}
+		return true
+	})
+	return template, found
+}
+
+// paramsOf collects the tags of the chain as params, the nearest value wins,
+// values of sensitive keys are masked.
+func paramsOf(err error) map[string]string {
+	params := make(map[string]string)
+	irr.Walk(err, func(err error) bool {
+		lister, ok := err.(irr.ITagLister)
+		if !ok {
+			return true
+		}
+		for _, tag := range lister.AllTags() {
+			if _, exist := params[tag.Key]; !exist {
+				params[tag.Key] = tag.Redacted().Value
+			}
+		}
+		return true
+	})
+	return params
+}
@@ -0,0 +1,77 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog_Localize(t *testing.T) {
+	c := newTestCatalog(t)
+
+	inner := testCodeUserNotFound.Error("no rows in users")
+	inner.SetTag("user", "u1")
+	err := irr.Wrap(inner, "load profile")
+	err.SetTag("user", "outer")
+
+	// 参数取自 tag，最近的值优先
+	assert.Equal(t, "User outer was not found", c.Localize(err, "en"))
+	assert.Equal(t, "未找到用户 outer", c.Localize(err, "zh-CN"))
+	assert.Equal(t, "找不到使用者 outer", c.Localize(err, "zh-TW,zh;q=0.8"))
+	assert.Equal(t, "User outer was not found", c.Localize(err, "fr"))
+	assert.Equal(t, "未找到用户 outer", c.Localize(fmt.Errorf("rpc: %w", err), "zh"))
+
+	// 消息 ID 优先于同一层的错误码
+	locked := WithMessageID(testCodeUserNotFound.Error("locked"), "order.locked")
+	locked.SetTag("order", "o-1")
+	assert.Equal(t, "订单 o-1 已锁定", c.Localize(locked, "zh"))
+	// 外层的错误码没有模板时继续向内查找
+	assert.Equal(t, "Order o-1 is locked", c.Localize(irr.Wrap(locked, "checkout").SetCode(int64(testCodeRegistered)), "de"))
+	assert.Equal(t, "Quota exceeded, try again in {retry_after}", c.Localize(testCodeQuota.Error("quota"), "en"))
+
+	// 在聚合错误的分支中查找
+	joined := irr.Wrap(irr.Join(errors.New("a"), locked), "batch")
+	assert.Equal(t, "Order o-1 is locked", c.Localize(joined, "en"))
+}
+
+func TestCatalog_LocalizeFallback(t *testing.T) {
+	c := newTestCatalog(t)
+	assert.Equal(t, "", c.Localize(nil, "en"))
+	assert.Equal(t, "", c.Localize(errors.New("plain"), "en"))
+
+	// 没有模板时依次使用公开消息与注册的消息
+	err := testCodeRegistered.Error("internal")
+	assert.Equal(t, "registered message", c.Localize(err, "en"))
+	err.SetPublicMsg("public message")
+	assert.Equal(t, "public message", c.Localize(irr.Wrap(err, "wrap"), "en"))
+}
+
+func TestCatalog_LocalizeRedaction(t *testing.T) {
+	irr.SetSensitiveKeys("user")
+	defer irr.SetSensitiveKeys()
+
+	err := testCodeUserNotFound.Error("no rows")
+	err.SetTag("user", "alice@example.com")
+	assert.Equal(t, "User *** was not found", newTestCatalog(t).Localize(err, "en"))
+}
+
+func TestLocalize(t *testing.T) {
+	code := irc.Code(94004)
+	DefaultCatalog.AddCode("en", code, "No user {user}")
+
+	err := code.Error("no rows")
+	err.SetTag("user", "u1")
+	assert.Equal(t, "No user u1", Localize(err, "en-US"))
+}
+
+func TestRender(t *testing.T) {
+	params := map[string]string{"a": "1", "b": "2"}
+	assert.Equal(t, "1 + 2 = {c}", Render("{a} + {b} = {c}", params))
+	assert.Equal(t, "{a", Render("{a", params))
+	assert.Equal(t, "x}1", Render("x}{a}", params))
+	assert.Equal(t, "{a}", Render("{a}", nil))
+}
@@ -8,7 +8,7 @@
 //
 // Best practices:
 //   - Define domain-specific error codes as constants using the `Code` type
-//     to maintain a registry of error codes for the application.
+//     and register their metadata with `MustRegister` at init time.
 //   - Use the `Code`-related methods to create errors with consistent formatting,
 //     additional context, and these predefined error codes.
 //   - Wrap errors when catching them to maintain the original error context, providing
@@ -20,8 +20,10 @@
 package irc
 
 import (
-	"github.com/khicago/irr"
+	"errors"
 	"strconv"
+
+	"github.com/khicago/irr"
 )
 
 type (
@@ -34,6 +36,9 @@ type (
 
 	// ICodeTraverse is an interface for traversing codes in an error chain.
 	ICodeTraverse irr.ITraverseCoder[int64]
+
+	// codeTarget is a code-only error value used as the target of errors.Is.
+	codeTarget Code
 )
 
 // Verify that Code implements the irr.Spawner interface.
@@ -44,11 +49,29 @@ func (c Code) I64() int64 {
 	return int64(c)
 }
 
-// String converts a Code to its string representation, typically for printing.
+// String returns the name registered in the DefaultRegistry, or the decimal
+// representation of the code when it is not registered.
 func (c Code) String() string {
+	if meta, ok := c.Meta(); ok {
+		return meta.Name
+	}
 	return strconv.FormatInt(c.I64(), 10)
 }
 
+// Target returns an error value carrying only the code. It can be used as the
+// target of errors.Is to check whether the code appears anywhere in an error chain.
+//
+//	if errors.Is(err, ErrNotFound.Target()) { ... }
+func (c Code) Target() error {
+	return codeTarget(c)
+}
+
+// Matches reports whether the code appears anywhere in the error chain of err.
+// It is a shorthand of errors.Is(err, c.Target()).
+func (c Code) Matches(err error) bool {
+	return errors.Is(err, c.Target())
+}
+
 // Error creates an IRR error object with a formatted message and sets the error code.
 func (c Code) Error(formatOrMsg string, args ...interface{}) irr.IRR {
 	return irr.Error(formatOrMsg, args...).SetCode(c.I64())
@@ -81,3 +104,13 @@ func (c Code) TrackSkip(skip int, innerErr error, formatOrMsg string, args ...in
 func (c Code) Track(innerErr error, formatOrMsg string, args ...interface{}) irr.IRR {
 	return irr.Track(innerErr, formatOrMsg, args...).SetCode(c.I64())
 }
+
+// Error implements the error interface of codeTarget.
+func (t codeTarget) Error() string {
+	return "code(" + strconv.FormatInt(int64(t), 10) + ")"
+}
+
+// CurrentCode returns the code carried by the target, it is what IRR errors compare in Is.
+func (t codeTarget) CurrentCode() int64 {
+	return int64(t)
+}
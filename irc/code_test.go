@@ -295,3 +295,18 @@ func TestCode_InterfaceCompliance(t *testing.T) {
 		t.Error("Error should implement ICoder interface")
 	}
 }
+
+func TestCode_Target(t *testing.T) {
+	err := irr.Wrap(TestCodeNotFound.Wrap(errors.New("no rows"), "user not found"), "handle request")
+
+	assert.True(t, errors.Is(err, TestCodeNotFound.Target()))
+	assert.False(t, errors.Is(err, TestCodeServerError.Target()))
+	assert.True(t, TestCodeNotFound.Matches(err))
+	assert.False(t, TestCodeBadRequest.Matches(err))
+	assert.False(t, TestCodeNotFound.Matches(nil))
+	assert.False(t, TestCodeNotFound.Matches(errors.New("plain")))
+
+	// 通过 fmt.Errorf 包装后依然可以匹配
+	assert.True(t, TestCodeNotFound.Matches(fmt.Errorf("rpc: %w", err)))
+	assert.Equal(t, "code(404)", TestCodeNotFound.Target().Error())
+}
@@ -3,23 +3,58 @@ package irc
 import (
 	"fmt"
 	"strings"
+
+	"github.com/khicago/irr"
 )
 
+// DumpToCodeNError 将错误转换为错误码与面向调用方的消息
+// 消息为链中的公开消息（irr.PublicMessage），没有时为错误码注册的消息，都没有时为空，
+// 内部的诊断信息不会输出。需要内部消息时使用 DumpToCodeNDetail
 func DumpToCodeNError(succ, unknown Code, err error, msgOrFmt string, args ...any) (code Code, msg string) {
 	if err == nil {
 		return succ, ""
 	}
+	code, _ = dumpCodeNDetail(unknown, err)
+	errMsg := irr.PublicMessage(err)
+	if errMsg == "" {
+		if meta, ok := code.Meta(); ok {
+			errMsg = meta.Message
+		}
+	}
+	return code, joinDumpMsg(errMsg, msgOrFmt, args)
+}
+
+// DumpToCodeNDetail 与 DumpToCodeNError 相同，但没有公开消息时使用去掉错误码前缀的 Error()，
+// 其中包含内部消息与 tag，只应在受信任的内部输出中使用
+func DumpToCodeNDetail(succ, unknown Code, err error, msgOrFmt string, args ...any) (code Code, msg string) {
+	if err == nil {
+		return succ, ""
+	}
+	code, errMsg := dumpCodeNDetail(unknown, err)
+	if public := irr.PublicMessage(err); public != "" {
+		errMsg = public
+	}
+	return code, joinDumpMsg(errMsg, msgOrFmt, args)
+}
 
+// joinDumpMsg 有格式化参数时将 msgOrFmt 格式化后作为前缀
+func joinDumpMsg(errMsg, msgOrFmt string, args []any) string {
+	if msgOrFmt == "" || len(args) == 0 {
+		return errMsg
+	}
 	sb := strings.Builder{}
-	appendMsg := msgOrFmt
-	if appendMsg != "" && len(args) > 0 {
-		appendMsg = fmt.Sprintf(msgOrFmt, args...)
-		sb.WriteString(appendMsg)
+	sb.WriteString(fmt.Sprintf(msgOrFmt, args...))
+	if errMsg != "" {
 		sb.WriteString(", ")
+		sb.WriteString(errMsg)
 	}
+	return sb.String()
+}
 
+// dumpCodeNDetail 返回错误的错误码与去掉错误码前缀的 Error()
+func dumpCodeNDetail(unknown Code, err error) (code Code, errMsg string) {
 	code = unknown
-	errMsg := err.Error()
+	errMsg = err.Error()
 
 	// 优先使用新的NearestCode API
 	if codet, ok := err.(interface{ NearestCode() int64 }); ok {
@@ -44,6 +79,5 @@ func DumpToCodeNError(succ, unknown Code, err error, msgOrFmt string, args ...an
 			errMsg = errMsg[lenCodeStr:]
 		}
 	}
-	sb.WriteString(errMsg)
-	return code, sb.String()
+	return code, errMsg
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/khicago/irr"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,7 +29,7 @@ func (e *customError) GetCodeStr() string {
 	return "custom_code(" + string(rune(e.code)) + "), "
 }
 
-func TestDumpToCodeNError(t *testing.T) {
+func TestDumpToCodeNDetail(t *testing.T) {
 	tests := []struct {
 		name       string
 		succ       Code
@@ -93,19 +94,19 @@ func TestDumpToCodeNError(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			code, msg := DumpToCodeNError(tt.succ, tt.unknown, tt.err, tt.msgOrFmt, tt.args...)
+			code, msg := DumpToCodeNDetail(tt.succ, tt.unknown, tt.err, tt.msgOrFmt, tt.args...)
 			assert.Equal(t, tt.expectCode, code)
 			assert.Equal(t, tt.expectMsg, msg)
 		})
 	}
 }
 
-func TestDumpToCodeNError_WithIRRError(t *testing.T) {
+func TestDumpToCodeNDetail_WithIRRError(t *testing.T) {
 	// 测试带有错误码的IRR错误
 	originalErr := TestCodeNotFound.Error("user not found")
 
 	// 需要提供args才能添加前缀消息
-	code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, originalErr, "service error for %s", "user123")
+	code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, originalErr, "service error for %s", "user123")
 
 	// 应该提取到原始错误码
 	assert.Equal(t, TestCodeNotFound, code)
@@ -117,12 +118,12 @@ func TestDumpToCodeNError_WithIRRError(t *testing.T) {
 	assert.NotContains(t, msg, "code(404), service error for user123, code(404)")
 }
 
-func TestDumpToCodeNError_WithWrappedIRRError(t *testing.T) {
+func TestDumpToCodeNDetail_WithWrappedIRRError(t *testing.T) {
 	// 测试包装的IRR错误
 	innerErr := errors.New("database connection failed")
 	wrappedErr := TestCodeServerError.Wrap(innerErr, "service unavailable")
 
-	code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, wrappedErr, "request failed for %s", "endpoint")
+	code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, wrappedErr, "request failed for %s", "endpoint")
 
 	// 应该提取到最近的错误码
 	assert.Equal(t, TestCodeServerError, code)
@@ -132,13 +133,13 @@ func TestDumpToCodeNError_WithWrappedIRRError(t *testing.T) {
 	assert.Contains(t, msg, "service unavailable")
 }
 
-func TestDumpToCodeNError_WithNestedIRRErrors(t *testing.T) {
+func TestDumpToCodeNDetail_WithNestedIRRErrors(t *testing.T) {
 	// 测试嵌套的IRR错误，验证ClosestCode的行为
 	originalErr := TestCodeNotFound.Error("user not found")
 	wrappedErr := TestCodeServerError.Wrap(originalErr, "service error")
 	trackedErr := TestCodeBadRequest.Track(wrappedErr, "request processing failed")
 
-	code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, trackedErr, "API error in %s", "handler")
+	code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, trackedErr, "API error in %s", "handler")
 
 	// 应该获取最外层（最近的）错误码
 	assert.Equal(t, TestCodeBadRequest, code)
@@ -148,7 +149,7 @@ func TestDumpToCodeNError_WithNestedIRRErrors(t *testing.T) {
 	assert.Contains(t, msg, "request processing failed")
 }
 
-func TestDumpToCodeNError_CodeStripping(t *testing.T) {
+func TestDumpToCodeNDetail_CodeStripping(t *testing.T) {
 	// 测试错误码字符串的剥离功能
 	originalErr := TestCodeNotFound.Error("user not found")
 
@@ -156,7 +157,7 @@ func TestDumpToCodeNError_CodeStripping(t *testing.T) {
 	originalMsg := originalErr.Error()
 	assert.Contains(t, originalMsg, "code(404)")
 
-	code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, originalErr, "")
+	code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, originalErr, "")
 
 	// 提取的消息应该去掉code(404)前缀
 	assert.Equal(t, TestCodeNotFound, code)
@@ -164,10 +165,42 @@ func TestDumpToCodeNError_CodeStripping(t *testing.T) {
 	assert.Contains(t, msg, "user not found")
 }
 
-func TestDumpToCodeNError_WithICodeGetter(t *testing.T) {
+func TestDumpToCodeNError_PublicMessage(t *testing.T) {
+	// 存在公开消息时不输出内部的诊断信息
+	inner := TestCodeNotFound.Error("select * from users where id=42: no rows")
+	inner.SetPublicMsg("user not found")
+	wrapped := irr.Wrap(inner, "load profile from replica-3")
+
+	code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, wrapped, "request %s", "r1")
+	assert.Equal(t, TestCodeNotFound, code)
+	assert.Equal(t, "request r1, user not found", msg)
+
+	// 外层的公开消息优先
+	wrapped.SetPublicMsg("profile unavailable")
+	_, msg = DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, wrapped, "")
+	assert.Equal(t, "profile unavailable", msg)
+}
+
+func TestDumpToCodeNError_HidesInternalMessages(t *testing.T) {
+	// 没有公开消息时使用注册的消息，内部消息与 tag 不会输出
+	err := testRegCodeNotFound.Error("select * from users where id=42: no rows")
+	err.SetTag("replica", "3")
+	code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, irr.Wrap(err, "load profile"), "request %s", "r1")
+	assert.Equal(t, testRegCodeNotFound, code)
+	assert.Equal(t, "request r1, resource not found", msg)
+
+	// 没有注册的消息时只保留前缀
+	code, msg = DumpToCodeNError(TestCodeSuccess, 599, errors.New("dial tcp 10.0.0.3:5432: refused"), "request %s", "r1")
+	assert.Equal(t, Code(599), code)
+	assert.Equal(t, "request r1", msg)
+	_, msg = DumpToCodeNError(TestCodeSuccess, 599, errors.New("dial tcp 10.0.0.3:5432: refused"), "")
+	assert.Equal(t, "", msg)
+}
+
+func TestDumpToCodeNDetail_WithICodeGetter(t *testing.T) {
 	customErr := &customError{code: 999, msg: "custom error message"}
 
-	code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, customErr, "wrapper message for %s", "test")
+	code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, customErr, "wrapper message for %s", "test")
 
 	// 应该提取到自定义错误码
 	assert.Equal(t, Code(999), code)
@@ -177,10 +210,10 @@ func TestDumpToCodeNError_WithICodeGetter(t *testing.T) {
 	assert.Contains(t, msg, "custom error message")
 }
 
-func TestDumpToCodeNError_EdgeCases(t *testing.T) {
+func TestDumpToCodeNDetail_EdgeCases(t *testing.T) {
 	t.Run("空消息格式", func(t *testing.T) {
 		err := errors.New("test error")
-		code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, err, "")
+		code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, err, "")
 		
 		assert.Equal(t, TestCodeUnknown, code)
 		assert.Equal(t, "test error", msg)
@@ -188,7 +221,7 @@ func TestDumpToCodeNError_EdgeCases(t *testing.T) {
 
 	t.Run("消息格式但无参数", func(t *testing.T) {
 		err := errors.New("test error")
-		code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, err, "prefix message")
+		code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, err, "prefix message")
 		
 		assert.Equal(t, TestCodeUnknown, code)
 		assert.Equal(t, "test error", msg)
@@ -196,7 +229,7 @@ func TestDumpToCodeNError_EdgeCases(t *testing.T) {
 
 	t.Run("消息格式和参数", func(t *testing.T) {
 		err := errors.New("test error")
-		code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, err, "prefix %s", "formatted")
+		code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, err, "prefix %s", "formatted")
 		
 		assert.Equal(t, TestCodeUnknown, code)
 		assert.Equal(t, "prefix formatted, test error", msg)
@@ -210,7 +243,7 @@ func TestDumpToCodeNError_EdgeCases(t *testing.T) {
 			message: "code(404), not found",
 		}
 		
-		code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, baseErr, "")
+		code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, baseErr, "")
 		
 		assert.Equal(t, Code(404), code)
 		assert.Equal(t, "not found", msg)
@@ -223,7 +256,7 @@ func TestDumpToCodeNError_EdgeCases(t *testing.T) {
 			message: "not found",
 		}
 		
-		code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, baseErr, "")
+		code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, baseErr, "")
 		
 		assert.Equal(t, Code(404), code)
 		assert.Equal(t, "not found", msg)
@@ -236,7 +269,7 @@ func TestDumpToCodeNError_EdgeCases(t *testing.T) {
 			message: "code(500), server error",
 		}
 		
-		code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, baseErr, "")
+		code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, baseErr, "")
 		
 		assert.Equal(t, Code(500), code)
 		assert.Equal(t, "server error", msg)
@@ -249,7 +282,7 @@ func TestDumpToCodeNError_EdgeCases(t *testing.T) {
 			message: "code(403), forbidden",
 		}
 		
-		code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, baseErr, "")
+		code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, baseErr, "")
 		
 		assert.Equal(t, Code(403), code)
 		assert.Equal(t, "forbidden", msg)
@@ -262,7 +295,7 @@ func TestDumpToCodeNError_EdgeCases(t *testing.T) {
 			message: "code(401), unauthorized",
 		}
 		
-		code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, baseErr, "")
+		code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, baseErr, "")
 		
 		assert.Equal(t, Code(401), code)
 		assert.Equal(t, "unauthorized", msg)
@@ -270,7 +303,7 @@ func TestDumpToCodeNError_EdgeCases(t *testing.T) {
 
 	t.Run("复杂格式化消息", func(t *testing.T) {
 		err := errors.New("database connection failed")
-		code, msg := DumpToCodeNError(TestCodeSuccess, TestCodeUnknown, err, "operation %s failed with %d retries", "connect", 3)
+		code, msg := DumpToCodeNDetail(TestCodeSuccess, TestCodeUnknown, err, "operation %s failed with %d retries", "connect", 3)
 		
 		assert.Equal(t, TestCodeUnknown, code)
 		assert.Equal(t, "operation connect failed with 3 retries, database connection failed", msg)
@@ -0,0 +1,227 @@
+package irc
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/khicago/irr"
+)
+
+type (
+	// Severity describes how serious an error code is.
+	Severity int
+
+	// Meta holds the metadata registered for a code.
+	Meta struct {
+		// Code is the registered code, it must not be 0.
+		Code Code `json:"code"`
+		// Name is the unique identifier of the code, e.g. "USER_NOT_FOUND".
+		Name string `json:"name"`
+		// Message is the default message of the code.
+		Message string `json:"message,omitempty"`
+		// Category groups codes, e.g. "system", "business".
+		Category string `json:"category,omitempty"`
+		// HTTPStatus is the HTTP status code the code maps to, 0 means unspecified.
+		HTTPStatus int `json:"http_status,omitempty"`
+		// GRPCCode is the gRPC status code (codes.Code) the code maps to, 0 means OK/unspecified.
+		GRPCCode uint32 `json:"grpc_code,omitempty"`
+		// Retryable reports whether an operation failed with the code can be retried.
+		// Registering doesn't classify the code by itself, call ApplyRetryClasses
+		// to make irr.IsRetryable recognize the retryable codes of a registry.
+		Retryable bool `json:"retryable,omitempty"`
+		// Severity is the severity level of the code.
+		Severity Severity `json:"severity,omitempty"`
+		// DocsURL points to the documentation of the code.
+		DocsURL string `json:"docs_url,omitempty"`
+	}
+
+	// Registry is a concurrent-safe registry of codes and their metadata.
+	// Both the code and the name of a Meta must be unique in a registry.
+	Registry struct {
+		mu     sync.RWMutex
+		byCode map[Code]Meta
+		byName map[string]Code
+	}
+)
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+	SeverityCritical
+)
+
+// The sentinels are plain errors, so that importing the package creates no IRR
+// errors, nor metrics, at init.
+var (
+	// ErrInvalidMeta is returned when registering a Meta with a zero code or an empty name.
+	ErrInvalidMeta = errors.New("invalid code meta")
+	// ErrDuplicateCode is returned when registering a code that is already registered.
+	ErrDuplicateCode = errors.New("duplicate code")
+	// ErrDuplicateName is returned when registering a name that is already registered.
+	ErrDuplicateName = errors.New("duplicate code name")
+
+	// DefaultRegistry is the registry used by the package level functions and Code.String.
+	DefaultRegistry = NewRegistry()
+)
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		byCode: make(map[Code]Meta),
+		byName: make(map[string]Code),
+	}
+}
+
+// Register adds the metadata of a code to the registry.
+// It fails when the code is 0, the name is empty, or either of them is already registered.
+func (r *Registry) Register(meta Meta) error {
+	if meta.Code == 0 || meta.Name == "" {
+		return irr.Wrap(ErrInvalidMeta, "code=%d name=%q", meta.Code, meta.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if exist, ok := r.byCode[meta.Code]; ok {
+		return irr.Wrap(ErrDuplicateCode, "code %d is already registered as %s", meta.Code, exist.Name)
+	}
+	if exist, ok := r.byName[meta.Name]; ok {
+		return irr.Wrap(ErrDuplicateName, "name %s is already registered by code %d", meta.Name, exist)
+	}
+	r.byCode[meta.Code] = meta
+	r.byName[meta.Name] = meta.Code
+	return nil
+}
+
+// MustRegister is like Register but panics on failure, so that mistakes are
+// found at init time. It returns the registered code for convenience.
+//
+//	var ErrUserNotFound = irc.MustRegister(irc.Meta{Code: 2001, Name: "USER_NOT_FOUND", HTTPStatus: 404})
+func (r *Registry) MustRegister(meta Meta) Code {
+	if err := r.Register(meta); err != nil {
+		panic(err)
+	}
+	return meta.Code
+}
+
+// Lookup returns the metadata of a code.
+func (r *Registry) Lookup(code Code) (Meta, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	meta, ok := r.byCode[code]
+	return meta, ok
+}
+
+// LookupName returns the metadata registered with the name.
+func (r *Registry) LookupName(name string) (Meta, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	code, ok := r.byName[name]
+	if !ok {
+		return Meta{}, false
+	}
+	return r.byCode[code], true
+}
+
+// All returns all the registered metadata ordered by code.
+func (r *Registry) All() []Meta {
+	r.mu.RLock()
+	metas := make([]Meta, 0, len(r.byCode))
+	for _, meta := range r.byCode {
+		metas = append(metas, meta)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(metas, func(i, j int) bool {
+		return metas[i].Code < metas[j].Code
+	})
+	return metas
+}
+
+// ApplyRetryClasses classifies the codes registered with Retryable as
+// irr.ClassRetryable (see irr.SetCodeRetryClass), codes that are already
+// classified keep their class. Call it once the codes are registered, usually
+// at startup; codes registered later are not classified until it's called again.
+func (r *Registry) ApplyRetryClasses() {
+	for _, meta := range r.All() {
+		if meta.Retryable && irr.CodeRetryClass(int64(meta.Code)) == irr.ClassUnknown {
+			irr.SetCodeRetryClass(int64(meta.Code), irr.ClassRetryable)
+		}
+	}
+}
+
+// Len returns the number of registered codes.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.byCode)
+}
+
+// Register adds the metadata of a code to the DefaultRegistry.
+func Register(meta Meta) error {
+	return DefaultRegistry.Register(meta)
+}
+
+// MustRegister adds the metadata of a code to the DefaultRegistry, and panics on failure.
+func MustRegister(meta Meta) Code {
+	return DefaultRegistry.MustRegister(meta)
+}
+
+// ApplyRetryClasses classifies the retryable codes of the DefaultRegistry, see Registry.ApplyRetryClasses.
+func ApplyRetryClasses() {
+	DefaultRegistry.ApplyRetryClasses()
+}
+
+// Lookup returns the metadata of a code from the DefaultRegistry.
+func Lookup(code Code) (Meta, bool) {
+	return DefaultRegistry.Lookup(code)
+}
+
+// LookupName returns the metadata registered with the name from the DefaultRegistry.
+func LookupName(name string) (Meta, bool) {
+	return DefaultRegistry.LookupName(name)
+}
+
+// Meta returns the metadata of the code from the DefaultRegistry.
+func (c Code) Meta() (Meta, bool) {
+	return DefaultRegistry.Lookup(c)
+}
+
+// PickMaxSeverity is an irr.CodePicker that picks the code with the highest
+// registered severity, codes that are not registered are regarded as SeverityError.
+// The first one wins when several codes have the same severity.
+func PickMaxSeverity(codes []int64) int64 {
+	val, maxSeverity := codes[0], Code(codes[0]).severity()
+	for _, code := range codes[1:] {
+		if severity := Code(code).severity(); severity > maxSeverity {
+			val, maxSeverity = code, severity
+		}
+	}
+	return val
+}
+
+func (c Code) severity() Severity {
+	if meta, ok := c.Meta(); ok {
+		return meta.Severity
+	}
+	return SeverityError
+}
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return "severity(" + strconv.Itoa(int(s)) + ")"
+	}
+}
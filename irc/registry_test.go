@@ -0,0 +1,130 @@
+package irc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/khicago/irr"
+	"github.com/stretchr/testify/assert"
+)
+
+// 注册到 DefaultRegistry 的测试错误码，避免与其他测试使用的错误码冲突
+const (
+	testRegCodeNotFound Code = 91404
+	testRegCodeCritical Code = 91500
+	testRegCodeWarning  Code = 91429
+)
+
+func init() {
+	MustRegister(Meta{
+		Code:       testRegCodeNotFound,
+		Name:       "TEST_NOT_FOUND",
+		Message:    "resource not found",
+		Category:   "business",
+		HTTPStatus: 404,
+		GRPCCode:   5,
+		DocsURL:    "https://example.com/errors/TEST_NOT_FOUND",
+	})
+	MustRegister(Meta{Code: testRegCodeCritical, Name: "TEST_CRITICAL", Severity: SeverityCritical})
+	MustRegister(Meta{Code: testRegCodeWarning, Name: "TEST_WARNING", Severity: SeverityWarning, Retryable: true})
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+	assert.NoError(t, r.Register(Meta{Code: 1001, Name: "A"}))
+	assert.NoError(t, r.Register(Meta{Code: 1000, Name: "B"}))
+	assert.Equal(t, 2, r.Len())
+
+	err := r.Register(Meta{Code: 1001, Name: "C"})
+	assert.True(t, errors.Is(err, ErrDuplicateCode), err)
+	assert.Contains(t, err.Error(), "already registered as A")
+
+	err = r.Register(Meta{Code: 1002, Name: "A"})
+	assert.True(t, errors.Is(err, ErrDuplicateName), err)
+
+	assert.True(t, errors.Is(r.Register(Meta{Code: 0, Name: "ZERO"}), ErrInvalidMeta))
+	assert.True(t, errors.Is(r.Register(Meta{Code: 1003}), ErrInvalidMeta))
+
+	// 失败的注册不会产生任何影响
+	assert.Equal(t, 2, r.Len())
+	_, ok := r.LookupName("C")
+	assert.False(t, ok)
+
+	all := r.All()
+	assert.Equal(t, []Meta{{Code: 1000, Name: "B"}, {Code: 1001, Name: "A"}}, all)
+}
+
+func TestRegistry_MustRegister(t *testing.T) {
+	r := NewRegistry()
+	assert.Equal(t, Code(1001), r.MustRegister(Meta{Code: 1001, Name: "A"}))
+	assert.Panics(t, func() {
+		r.MustRegister(Meta{Code: 1001, Name: "A2"})
+	})
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	meta, ok := Lookup(testRegCodeNotFound)
+	assert.True(t, ok)
+	assert.Equal(t, "TEST_NOT_FOUND", meta.Name)
+	assert.Equal(t, 404, meta.HTTPStatus)
+	assert.Equal(t, uint32(5), meta.GRPCCode)
+
+	meta, ok = LookupName("TEST_WARNING")
+	assert.True(t, ok)
+	assert.Equal(t, testRegCodeWarning, meta.Code)
+	assert.True(t, meta.Retryable)
+
+	_, ok = Lookup(91999)
+	assert.False(t, ok)
+	_, ok = LookupName("TEST_NOT_EXIST")
+	assert.False(t, ok)
+
+	meta, ok = testRegCodeCritical.Meta()
+	assert.True(t, ok)
+	assert.Equal(t, SeverityCritical, meta.Severity)
+}
+
+func TestCode_StringRegistered(t *testing.T) {
+	assert.Equal(t, "TEST_NOT_FOUND", testRegCodeNotFound.String())
+	assert.Equal(t, "91999", Code(91999).String())
+	assert.Contains(t, testRegCodeNotFound.Error("oops").Error(), "code(91404)")
+}
+
+func TestPickMaxSeverity(t *testing.T) {
+	codes := []int64{int64(testRegCodeWarning), 91999, int64(testRegCodeCritical), int64(testRegCodeNotFound)}
+	assert.Equal(t, int64(testRegCodeCritical), PickMaxSeverity(codes))
+	// 未注册的错误码视为 SeverityError
+	assert.Equal(t, int64(91999), PickMaxSeverity([]int64{int64(testRegCodeWarning), 91999}))
+	assert.Equal(t, int64(testRegCodeWarning), PickMaxSeverity([]int64{int64(testRegCodeWarning)}))
+}
+
+func TestSeverity_String(t *testing.T) {
+	assert.Equal(t, "info", SeverityInfo.String())
+	assert.Equal(t, "warning", SeverityWarning.String())
+	assert.Equal(t, "error", SeverityError.String())
+	assert.Equal(t, "critical", SeverityCritical.String())
+	assert.Equal(t, "severity(9)", Severity(9).String())
+}
+
+func TestApplyRetryClasses(t *testing.T) {
+	code := MustRegister(Meta{Code: 91503, Name: "TEST_RETRYABLE", Retryable: true})
+	// 注册本身没有副作用
+	assert.Equal(t, irr.ClassUnknown, irr.CodeRetryClass(int64(code)))
+
+	irr.SetCodeRetryClass(91505, irr.ClassPermanent)
+	defer irr.SetCodeRetryClass(91505, irr.ClassUnknown)
+	MustRegister(Meta{Code: 91505, Name: "TEST_CLASSIFIED", Retryable: true})
+
+	ApplyRetryClasses()
+	assert.Equal(t, irr.ClassRetryable, irr.CodeRetryClass(int64(code)))
+	assert.True(t, irr.IsRetryable(irr.Wrap(code.Error("unavailable"), "call")))
+	// 已有的分类不会被覆盖
+	assert.Equal(t, irr.ClassPermanent, irr.CodeRetryClass(91505))
+
+	// 独立的注册表同样需要显式应用
+	r := NewRegistry()
+	assert.NoError(t, r.Register(Meta{Code: 91504, Name: "TEST_LOCAL_RETRYABLE", Retryable: true}))
+	assert.Equal(t, irr.ClassUnknown, irr.CodeRetryClass(91504))
+	r.ApplyRetryClasses()
+	assert.Equal(t, irr.ClassRetryable, irr.CodeRetryClass(91504))
+}
@@ -60,6 +60,7 @@ func TraceSkip(skip int, formatOrMsg string, args ...any) IRR {
 	recordErrorWithTrace()
 	err := newBasicIrr(formatOrMsg, args...)
 	err.Trace = createTraceInfo(skip+1, nil)
+	recordHotspot(err)
 	return err
 }
 
@@ -83,6 +84,7 @@ func TrackSkip(skip int, innerErr error, formatOrMsg string, args ...any) IRR {
 	err := newBasicIrr(formatOrMsg, args...)
 	err.inner = innerErr
 	err.Trace = createTraceInfo(skip+1, innerErr)
+	recordHotspot(err)
 	return err
 }
 
@@ -95,6 +97,32 @@ func Track(innerErr error, formatOrMsg string, args ...any) IRR {
 	return TrackSkip(1, innerErr, formatOrMsg, args...)
 }
 
+// TraceFull creates an error object with the full call stack and a formatted message.
+// Unlike Trace, which records only the handling-stack frame, TraceFull records up to
+// the depth set by SetFullStackDepth frames, and they are symbolized lazily when printed.
+// It is suitable for panics and deep library code, the cheap Trace should be preferred otherwise.
+func TraceFull(formatOrMsg string, args ...any) IRR {
+	recordErrorCreated()
+	recordErrorWithTrace()
+	err := newBasicIrr(formatOrMsg, args...)
+	err.Trace = createFullTraceInfo(1, nil)
+	recordHotspot(err)
+	return err
+}
+
+// TrackFull creates an error object with the full call stack and wraps an inner error.
+// See TraceFull for the details of the full call stack.
+func TrackFull(innerErr error, formatOrMsg string, args ...any) IRR {
+	recordErrorCreated()
+	recordErrorWrapped()
+	recordErrorWithTrace()
+	err := newBasicIrr(formatOrMsg, args...)
+	err.inner = innerErr
+	err.Trace = createFullTraceInfo(1, innerErr)
+	recordHotspot(err)
+	return err
+}
+
 // CatchFailure is used to catch and handle panics within a function, preventing them from causing the program to crash while unifying the encapsulation of non-error information.
 // It is declared at the beginning of a function with the defer keyword, ensuring that any panic during function execution can be caught.
 // This function takes a callback function as a parameter, which is called when a panic occurs to handle the recovered error.
@@ -149,12 +177,3 @@ func CatchFailure(set func(err error)) {
 	}
 	set(Wrap(ErrUntypedExecutionFailure, "panic = %v", r))
 }
-
-//func (ir *BasicIrr) Format(s fmt.State, verb rune) {
-//	switch verb {
-//	case 'q':
-//		io.WriteString(s, fmt.Sprintf("%q", ir.Error()))
-//	case 's', 'v':
-//		io.WriteString(s, ir.Error())
-//	}
-//}
@@ -3,7 +3,10 @@ package irr
 import (
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func BenchmarkError(b *testing.B) {
@@ -142,3 +145,151 @@ func BenchmarkStdWrap(b *testing.B) {
 		_ = fmt.Errorf("wrap error %d: %w", i, baseErr)
 	}
 }
+
+func BenchmarkTraceFull(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = TraceFull("test error %d", i)
+	}
+}
+
+func BenchmarkToStringWithFullTrace(b *testing.B) {
+	err := TraceFull("test error")
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = err.ToString(true, "\n")
+	}
+}
+
+// baselineMetrics 是改为 MetricsSink 之前的统计实现：计数器原子更新，错误码计数用读写锁保护的 map
+// 原实现直接写入 LastErrorTime 存在数据竞争，这里改为原子写入，其余保持不变，作为对照
+type baselineMetrics struct {
+	created, withCode, withTrace, wrapped, traverse int64
+
+	lastErrorNano  atomic.Int64
+	codeStats      map[int64]int64
+	codeStatsMutex sync.RWMutex
+}
+
+func (m *baselineMetrics) OnCreated() {
+	atomic.AddInt64(&m.created, 1)
+	m.lastErrorNano.Store(time.Now().UnixNano())
+}
+
+func (m *baselineMetrics) OnCode(code int64) {
+	atomic.AddInt64(&m.withCode, 1)
+
+	m.codeStatsMutex.Lock()
+	m.codeStats[code]++
+	m.codeStatsMutex.Unlock()
+}
+
+func (m *baselineMetrics) OnTraced()   { atomic.AddInt64(&m.withTrace, 1) }
+func (m *baselineMetrics) OnWrapped()  { atomic.AddInt64(&m.wrapped, 1) }
+func (m *baselineMetrics) OnTraverse() { atomic.AddInt64(&m.traverse, 1) }
+
+// metricsStores 对比原有的加锁统计与当前的无锁统计
+var metricsStores = []struct {
+	name string
+	new  func() MetricsSink
+}{
+	{"baseline", func() MetricsSink { return &baselineMetrics{codeStats: make(map[int64]int64)} }},
+	{"lockfree", func() MetricsSink { return NewErrorMetrics() }},
+}
+
+func BenchmarkErrorCParallel(b *testing.B) {
+	prev := MetricsSinks()
+	defer SetMetricsSinks(prev...)
+
+	for _, store := range metricsStores {
+		b.Run(store.name, func(b *testing.B) {
+			SetMetricsSinks(store.new())
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int64(0)
+				for pb.Next() {
+					i++
+					_ = ErrorC(1001+i%8, "test error")
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkSetCodeParallel(b *testing.B) {
+	prev := MetricsSinks()
+	defer SetMetricsSinks(prev...)
+
+	for _, store := range metricsStores {
+		b.Run(store.name, func(b *testing.B) {
+			SetMetricsSinks(store.new())
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				err := Error("test error")
+				i := int64(0)
+				for pb.Next() {
+					i++
+					err.SetCode(1001 + i%8)
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkSetCodeContended 所有 goroutine 设置同一个错误码，即 pprof 中的热点场景
+func BenchmarkSetCodeContended(b *testing.B) {
+	prev := MetricsSinks()
+	defer SetMetricsSinks(prev...)
+
+	for _, store := range metricsStores {
+		b.Run(store.name, func(b *testing.B) {
+			SetMetricsSinks(store.new())
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				err := Error("test error")
+				for pb.Next() {
+					err.SetCode(1001)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMetricsOnCodeParallel(b *testing.B) {
+	for _, store := range metricsStores {
+		b.Run(store.name, func(b *testing.B) {
+			sink := store.new()
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int64(0)
+				for pb.Next() {
+					i++
+					sink.OnCode(1001 + i%8)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkWindowStatsOnCodeParallel(b *testing.B) {
+	s := NewWindowStats(nil)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int64(0)
+		for pb.Next() {
+			i++
+			s.OnCode(1001 + i%8)
+		}
+	})
+}
+
+func BenchmarkMetricsOnCreatedParallel(b *testing.B) {
+	m := NewErrorMetrics()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			m.OnCreated()
+		}
+	})
+}
@@ -3,9 +3,11 @@ package irr
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type (
@@ -13,14 +15,23 @@ type (
 		inner error
 
 		Code    int64      `json:"code"`
-		codeSet bool       `json:"code_set"` // 跟踪是否显式设置过错误码
+		codeSet bool       // 跟踪是否显式设置过错误码
 		Msg     string     `json:"msg"`
+		rawMsg  string     // 参数中包含 Secret 时保存原始的消息
 		Trace   *traceInfo `json:"trace"`
 
-		// 使用 map 替代 slice，提升查找性能
+		// 面向最终用户的消息，与用于诊断的 Msg 分离
+		publicMsg string
+		// 显式的重试分类与重试等待时间提示
+		retryClass RetryClass
+		retryAfter time.Duration
+
+		// 按插入顺序保存 tag，并以 map 索引提升查找性能
 		// 使用原子操作的指针，减少锁竞争
-		tags atomic.Pointer[map[string][]string] `json:"-"`
-		mu   sync.RWMutex                        // 保留锁用于tag操作的原子性
+		tags atomic.Pointer[tagStore] `json:"-"`
+		// 带类型的属性，与 tags 一样采用写时复制
+		attrs atomic.Pointer[[]slog.Attr]
+		mu    sync.RWMutex // 保留锁用于tag操作的原子性
 	}
 )
 
@@ -28,6 +39,7 @@ func newBasicIrr(formatOrMsg string, args ...any) *BasicIrr {
 	err := &BasicIrr{}
 	if len(args) > 0 {
 		err.Msg = fmt.Sprintf(formatOrMsg, args...)
+		err.rawMsg = revealMsg(formatOrMsg, args)
 	} else {
 		err.Msg = formatOrMsg
 	}
@@ -48,6 +60,32 @@ func (ir *BasicIrr) Unwrap() error {
 	return ir.inner
 }
 
+// Is
+// the implementation of errors.Is, two errors are regarded as the same
+// when they carry the same non-zero code. Thus errors.Is(err, target)
+// matches as long as any layer of err has the code of target, and a
+// code-only target (such as irc.Code.Target) can be used directly.
+func (ir *BasicIrr) Is(target error) bool {
+	if ir.Code == 0 || target == nil {
+		return false
+	}
+	if t, ok := target.(interface{ CurrentCode() int64 }); ok {
+		return t.CurrentCode() == ir.Code
+	}
+	return false
+}
+
+// As
+// the implementation of errors.As, it allows types embedding BasicIrr
+// (e.g. ContextualIrr) to be extracted as *BasicIrr
+func (ir *BasicIrr) As(target any) bool {
+	if t, ok := target.(**BasicIrr); ok {
+		*t = ir
+		return true
+	}
+	return false
+}
+
 // Root
 // the implementation of ITraverseError
 func (ir *BasicIrr) Root() error {
@@ -63,23 +101,12 @@ func (ir *BasicIrr) Root() error {
 
 // TraverseToRoot
 // the implementation of ITraverseError
+// when an error in the chain holds multiple errors (e.g. MultiIrr or the
+// result of errors.Join), all the branches are traversed depth-first
 func (ir *BasicIrr) TraverseToRoot(fn func(err error) error) (err error) {
 	recordTraverseOp()
-	defer func() {
-		if r := recover(); r != nil {
-			if e, ok := r.(error); ok {
-				err = e
-			} else {
-				err = Wrap(ErrUntypedExecutionFailure, "panic = %v", r)
-			}
-		}
-	}()
-	for inner := error(ir); inner != nil; inner = errors.Unwrap(inner) {
-		if err = fn(inner); err != nil {
-			return err
-		}
-	}
-	return
+	defer catchTraversePanic(&err)
+	return walkToRoot(ir, fn)
 }
 
 // Source
@@ -96,33 +123,11 @@ func (ir *BasicIrr) Source() (err error) {
 
 // TraverseToSource
 // the implementation of ITraverseIrr
+// when the chain ends with multiple errors, each branch reaches its own source
 func (ir *BasicIrr) TraverseToSource(fn func(err error, isSource bool) error) (err error) {
 	recordTraverseOp()
-	defer func() {
-		if r := recover(); r != nil {
-			if e, ok := r.(error); ok {
-				err = e
-			} else {
-				err = Wrap(ErrUntypedExecutionFailure, "panic = %v", r)
-			}
-		}
-	}()
-	for cur := ir; cur != nil; {
-		isCurSource := cur.inner == nil
-		err = fn(cur, isCurSource)
-		if isCurSource {
-			// 只有在 source 时才返回函数的结果
-			return err
-		}
-		if next, ok := cur.inner.(*BasicIrr); ok {
-			cur = next
-			continue
-		}
-		// 到达最后一个非 BasicIrr 的错误，这是 source
-		err = fn(cur.inner, true)
-		return err
-	}
-	return nil
+	defer catchTraversePanic(&err)
+	return walkToSource(ir, fn)
 }
 
 // GetCodeStr
@@ -136,29 +141,25 @@ func (ir *BasicIrr) GetCodeStr() string {
 	return fmt.Sprintf("code(%d), ", ir.Code)
 }
 
-func (ir *BasicIrr) writeSelfTo(sb *strings.Builder, printTrace bool, printCode bool) {
+func (ir *BasicIrr) writeSelfTo(sb *strings.Builder, printTrace bool, printCode bool, reveal bool) {
 	if printCode {
 		if codeStr := ir.GetCodeStr(); codeStr != "" {
 			sb.WriteString(codeStr)
 		}
 	}
-	sb.WriteString(ir.Msg)
+	if reveal {
+		sb.WriteString(ir.RevealMsg())
+	} else {
+		sb.WriteString(ir.Msg)
+	}
 
 	// 获取tags进行输出
-	if tagMap := ir.tags.Load(); tagMap != nil && len(*tagMap) > 0 {
-		for key, values := range *tagMap {
-			for _, value := range values {
-				sb.WriteRune('[')
-				sb.WriteString(key)
-				sb.WriteRune(':')
-				sb.WriteString(value)
-				sb.WriteString("] ")
-			}
-		}
-	}
+	ir.writeTagsTo(sb, reveal)
+	ir.writeAttrsTo(sb, reveal)
 	if printTrace && ir.Trace != nil {
 		sb.WriteRune(' ')
 		ir.Trace.writeTo(sb)
+		ir.Trace.writeStackTo(sb)
 	}
 }
 
@@ -166,21 +167,28 @@ func (ir *BasicIrr) writeSelfTo(sb *strings.Builder, printTrace bool, printCode
 // consecutive equal codes will be printed only once during the traceback process
 func (ir *BasicIrr) ToString(printTrace bool, split string) string {
 	sb := strings.Builder{}
+	ir.writeChainTo(&sb, printTrace, split, false)
+	return sb.String()
+}
+
+// writeChainTo 输出整条链，reveal 为 true 时输出 Secret 参数与敏感 key 的原始值
+func (ir *BasicIrr) writeChainTo(sb *strings.Builder, printTrace bool, split string, reveal bool) {
 	lastCode := int64(0)
-	_ = ir.TraverseToSource(func(err error, isSource bool) error {
-		if irr, ok := err.(*BasicIrr); ok {
-			// since have to continue traversing, irr only output itself
-			irr.writeSelfTo(&sb, printTrace, lastCode != irr.Code)
-			lastCode = irr.Code
-		} else {
-			sb.WriteString(err.Error())
+	for cur := ir; ; {
+		// since have to continue traversing, cur only output itself
+		cur.writeSelfTo(sb, printTrace, lastCode != cur.Code, reveal)
+		lastCode = cur.Code
+		if cur.inner == nil {
+			return
 		}
-		if !isSource {
-			sb.WriteString(split)
+		sb.WriteString(split)
+		next, ok := cur.inner.(*BasicIrr)
+		if !ok {
+			writeErrTo(sb, cur.inner, printTrace, split, reveal)
+			return
 		}
-		return nil
-	})
-	return sb.String()
+		cur = next
+	}
 }
 
 // LogWarn
@@ -225,7 +233,12 @@ func (ir *BasicIrr) SetCode(val int64) IRR {
 func (ir *BasicIrr) NearestCode() int64 {
 	eExit := errors.New("stop")
 	var val int64
-	if err := ir.TraverseCode(func(_ error, code int64) error {
+	if err := ir.TraverseCode(func(err error, code int64) error {
+		if m, ok := err.(*MultiIrr); ok {
+			// 聚合错误按其自身的策略选择错误码
+			val = m.NearestCode()
+			return eExit
+		}
 		if code != 0 {
 			val = code
 			return eExit
@@ -243,31 +256,20 @@ func (ir *BasicIrr) CurrentCode() int64 {
 }
 
 // RootCode 返回错误链根部的错误码
+// 错误链以聚合错误结尾时，按聚合错误的策略选择各分支根部的错误码
 func (ir *BasicIrr) RootCode() int64 {
-	var rootCode int64
-	var rootErr error
-
-	// 先获取根错误
-	_ = ir.TraverseToSource(func(err error, isSource bool) error {
-		if isSource {
-			rootErr = err
-		}
-		return nil
-	})
-
-	// 检查根错误是否有错误码
-	if rootErr != nil {
-		if t, ok := rootErr.(*BasicIrr); ok {
-			rootCode = t.Code
-		} else if t, ok := rootErr.(interface{ CurrentCode() int64 }); ok {
-			rootCode = t.CurrentCode()
-		} else if t, ok := rootErr.(interface{ GetCode() int64 }); ok {
-			// 兼容其他实现了GetCode的错误类型
-			rootCode = t.GetCode()
+	cur := ir
+	for {
+		next, ok := cur.inner.(*BasicIrr)
+		if !ok {
+			break
 		}
+		cur = next
 	}
-
-	return rootCode
+	if cur.inner == nil {
+		return cur.Code
+	}
+	return rootCodeOf(cur.inner)
 }
 
 // HasCurrentCode 检查当前错误对象是否显式设置了错误码
@@ -298,17 +300,7 @@ func (ir *BasicIrr) ClosestCode() int64 {
 // the implementation of ITraverseCoder[int64]
 func (ir *BasicIrr) TraverseCode(fn func(err error, code int64) error) (err error) {
 	return ir.TraverseToRoot(func(err error) error {
-		var code int64
-		if t, ok := err.(interface{ CurrentCode() int64 }); ok {
-			code = t.CurrentCode()
-		} else if t, ok := err.(interface{ GetCode() int64 }); ok {
-			// 兼容其他实现了GetCode的错误类型
-			code = t.GetCode()
-		}
-		if err = fn(err, code); err != nil {
-			return err
-		}
-		return nil
+		return fn(err, codeOf(err))
 	})
 }
 
@@ -318,40 +310,29 @@ func (ir *BasicIrr) SetTag(key, val string) {
 	ir.mu.Lock()
 	defer ir.mu.Unlock()
 
-	// 获取当前的tags map
-	currentTags := ir.tags.Load()
-	var newTags map[string][]string
-
-	if currentTags == nil {
-		newTags = make(map[string][]string)
-	} else {
-		// 复制现有的tags
-		newTags = make(map[string][]string, len(*currentTags))
-		for k, v := range *currentTags {
-			newTags[k] = make([]string, len(v))
-			copy(newTags[k], v)
-		}
-	}
-
-	// 添加新的tag
-	newTags[key] = append(newTags[key], val)
-	ir.tags.Store(&newTags)
+	// 基于当前的tags创建副本并添加新的tag
+	ir.tags.Store(ir.tags.Load().with(key, val))
 }
 
 // GetTag
 // the implementation of ITagger
 func (ir *BasicIrr) GetTag(key string) (val []string) {
-	tagMap := ir.tags.Load()
-	if tagMap == nil {
-		return nil
+	var values []string
+	if store := ir.tags.Load(); store != nil {
+		values = store.index[key]
 	}
-	values := (*tagMap)[key]
-	if values == nil {
+	// 字符串类型的属性同样可以通过 GetTag 获取
+	attr, ok := ir.GetAttr(key)
+	hasStrAttr := ok && attr.Kind() == slog.KindString
+	if values == nil && !hasStrAttr {
 		return nil
 	}
 	// 返回副本以避免竞态条件
-	result := make([]string, len(values))
+	result := make([]string, len(values), len(values)+1)
 	copy(result, values)
+	if hasStrAttr {
+		result = append(result, attr.String())
+	}
 	return result
 }
 
@@ -47,10 +47,16 @@ func TestIrrToString(t *testing.T) {
 func TestIrrFmtV(t *testing.T) {
 	str := fmt.Sprintf("%v", theIrr)
 	assert.Equal(t, "test err 1, inner error, source error => root error", str, "")
-	str = fmt.Sprintf("%+v", theIrr)
+	str = fmt.Sprintf("%s", theIrr)
 	assert.Equal(t, "test err 1, inner error, source error => root error", str, "")
+	str = fmt.Sprintf("%+v", theIrr)
+	assert.Equal(t, theIrr.ToString(true, "\n"), str, "")
 	str = fmt.Sprintf("%+q", theIrr)
 	assert.Equal(t, "\"test err 1, inner error, source error => root error\"", str, "")
+	str = fmt.Sprintf("%q", theIrr)
+	assert.Equal(t, "\"test err 1, inner error, source error => root error\"", str, "")
+	str = fmt.Sprintf("%d", theIrr)
+	assert.Equal(t, "%!d(test err 1, inner error, source error => root error)", str, "")
 }
 
 func TestIrrError(t *testing.T) {
@@ -143,6 +149,46 @@ func TestIrrTraverseToRootThrownErr(t *testing.T) {
 	assert.Exactly(t, true, errors.Is(err, ErrUntypedExecutionFailure), "should returns ErrUntypedExecutionFailure")
 }
 
+func TestIrrIs(t *testing.T) {
+	notFound := ErrorC(404, "user not found")
+	wrapped := Wrap(Wrap(notFound, "load profile"), "handle request")
+
+	// 相同错误码即视为匹配，不要求是同一个对象
+	assert.True(t, errors.Is(wrapped, ErrorC(404, "")))
+	assert.True(t, errors.Is(wrapped, wrapped))
+	assert.False(t, errors.Is(wrapped, ErrorC(500, "")))
+
+	// 零错误码不参与按码匹配
+	assert.False(t, errors.Is(Error("a"), Error("b")))
+	assert.False(t, errors.Is(ErrorC(404, "a"), Error("b")))
+
+	// 仍然可以匹配链中的标准错误
+	assert.True(t, errors.Is(theIrr, rootErr))
+	assert.False(t, notFound.(*BasicIrr).Is(nil))
+}
+
+func TestIrrAs(t *testing.T) {
+	ctxErr := ErrorWithContext(context.Background(), "with ctx")
+	ctxErr.SetCode(404)
+	wrapped := Wrap(ctxErr, "outer")
+
+	var ce *ContextualIrr
+	assert.True(t, errors.As(wrapped, &ce))
+
+	var basic *BasicIrr
+	assert.True(t, errors.As(ctxErr, &basic))
+	assert.Equal(t, int64(404), basic.Code)
+
+	// ContextualIrr 同样按错误码匹配
+	assert.True(t, errors.Is(wrapped, ErrorC(404, "")))
+
+	var target interface{ NearestCode() int64 }
+	assert.True(t, errors.As(wrapped, &target))
+	assert.Equal(t, int64(404), target.NearestCode())
+
+	assert.False(t, basic.As(&ce))
+}
+
 func TestLog(t *testing.T) {
 	l := &testLogger{}
 	ir := theIrr.LogWarn(l)
@@ -1307,10 +1353,14 @@ func TestAdditionalCoverage(t *testing.T) {
 		// 测试真正相同trace的情况 - 通过直接比较
 		if basicIrr, ok := irrErrWithTrace.(*BasicIrr); ok && basicIrr.Trace != nil {
 			// 创建一个具有完全相同trace的情况
-			sameTrace := *basicIrr.Trace // 复制trace
+			sameTrace := &traceInfo{ // 复制trace
+				FuncName: basicIrr.Trace.FuncName,
+				FileName: basicIrr.Trace.FileName,
+				Line:     basicIrr.Trace.Line,
+			}
 			testErr := Error("test")
 			if testBasicIrr, ok := testErr.(*BasicIrr); ok {
-				testBasicIrr.Trace = &sameTrace
+				testBasicIrr.Trace = sameTrace
 			}
 			
 			// 现在测试createTraceInfo
@@ -0,0 +1,88 @@
+package irrgrpc
+
+import (
+	"context"
+
+	"github.com/khicago/irr"
+	"google.golang.org/grpc"
+)
+
+// clientStream converts the errors of the wrapped stream.
+type clientStream struct {
+	grpc.ClientStream
+	c *Converter
+}
+
+// UnaryServerInterceptor converts the errors returned by handlers into statuses,
+// panics are recovered into errors with the full call stack. The DefaultConverter
+// is used when c is nil.
+func UnaryServerInterceptor(c *Converter) grpc.UnaryServerInterceptor {
+	c = orDefault(c)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer irr.CatchFailure(c.recoverTo(info.FullMethod, &err))
+		resp, err = handler(ctx, req)
+		return resp, c.Err(err)
+	}
+}
+
+// StreamServerInterceptor converts the errors returned by stream handlers into
+// statuses, panics are recovered into errors with the full call stack. The
+// DefaultConverter is used when c is nil.
+func StreamServerInterceptor(c *Converter) grpc.StreamServerInterceptor {
+	c = orDefault(c)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer irr.CatchFailure(c.recoverTo(info.FullMethod, &err))
+		return c.Err(handler(srv, ss))
+	}
+}
+
+// UnaryClientInterceptor converts the status errors of calls back into IRR errors.
+// The DefaultConverter is used when c is nil.
+func UnaryClientInterceptor(c *Converter) grpc.UnaryClientInterceptor {
+	c = orDefault(c)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return c.FromError(invoker(ctx, method, req, reply, cc, opts...))
+	}
+}
+
+// StreamClientInterceptor converts the status errors of streams back into IRR errors,
+// io.EOF is kept as it is. The DefaultConverter is used when c is nil.
+func StreamClientInterceptor(c *Converter) grpc.StreamClientInterceptor {
+	c = orDefault(c)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, c.FromError(err)
+		}
+		return &clientStream{ClientStream: cs, c: c}, nil
+	}
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	return s.c.FromError(s.ClientStream.SendMsg(m))
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	return s.c.FromError(s.ClientStream.RecvMsg(m))
+}
+
+func (s *clientStream) CloseSend() error {
+	return s.c.FromError(s.ClientStream.CloseSend())
+}
+
+// recoverTo returns the callback of irr.CatchFailure, which sets the recovered
+// panic to err as a status error with the full call stack.
+func (c *Converter) recoverTo(method string, err *error) func(e error) {
+	return func(e error) {
+		if e != nil {
+			*err = c.Err(irr.TrackFull(e, "panic recovered, %s", method))
+		}
+	}
+}
+
+func orDefault(c *Converter) *Converter {
+	if c != nil {
+		return c
+	}
+	return DefaultConverter
+}
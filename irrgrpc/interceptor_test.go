@@ -0,0 +1,166 @@
+package irrgrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/khicago/irr"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// 测试服务没有生成代码，请求与响应均为 StringValue，请求的值决定服务端的行为
+const (
+	testCallMethod   = "/irrgrpc.test.Echo/Call"
+	testStreamMethod = "/irrgrpc.test.Echo/Stream"
+)
+
+var testStreamDesc = grpc.StreamDesc{StreamName: "Stream", ServerStreams: true}
+
+func testBehavior(val string) (*wrapperspb.StringValue, error) {
+	switch val {
+	case "irr":
+		err := testCodeUserNotFound.Error("user not found")
+		err.SetTag("user", "u1")
+		return nil, irr.Wrap(err, "get user")
+	case "panic":
+		panic("boom")
+	case "status":
+		return nil, status.Error(codes.AlreadyExists, "exists")
+	case "plain":
+		return nil, errors.New("plain")
+	}
+	return wrapperspb.String("echo " + val), nil
+}
+
+func newTestConn(t *testing.T) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(nil)),
+		grpc.StreamInterceptor(StreamServerInterceptor(nil)),
+	)
+	streamDesc := testStreamDesc
+	streamDesc.Handler = func(srv any, stream grpc.ServerStream) error {
+		in := &wrapperspb.StringValue{}
+		if err := stream.RecvMsg(in); err != nil {
+			return err
+		}
+		out, err := testBehavior(in.Value)
+		if err != nil {
+			return err
+		}
+		return stream.SendMsg(out)
+	}
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "irrgrpc.test.Echo",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Call",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				in := &wrapperspb.StringValue{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req any) (any, error) {
+					return testBehavior(req.(*wrapperspb.StringValue).Value)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: testCallMethod}, handler)
+			},
+		}},
+		Streams: []grpc.StreamDesc{streamDesc},
+	}, struct{}{})
+	go func() { _ = server.Serve(lis) }()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(nil)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(nil)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		server.Stop()
+	})
+	return conn
+}
+
+func TestUnaryInterceptors(t *testing.T) {
+	conn := newTestConn(t)
+	call := func(val string) (string, error) {
+		out := &wrapperspb.StringValue{}
+		err := conn.Invoke(context.Background(), testCallMethod, wrapperspb.String(val), out)
+		return out.Value, err
+	}
+
+	out, err := call("hi")
+	assert.NoError(t, err)
+	assert.Equal(t, "echo hi", out)
+
+	_, err = call("irr")
+	assert.True(t, testCodeUserNotFound.Matches(err), err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	ir, ok := err.(irr.IRR)
+	assert.True(t, ok)
+	assert.Equal(t, int64(testCodeUserNotFound), ir.NearestCode())
+	// 默认只还原错误码，内部消息与标签不会发送到客户端
+	assert.Equal(t, "code(93001), NotFound", err.Error())
+
+	// 服务端的 panic 被恢复，调用栈不会发送到客户端
+	_, err = call("panic")
+	assert.Equal(t, codes.Unknown, status.Code(err))
+	assert.Equal(t, "Unknown", err.Error())
+
+	_, err = call("status")
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	assert.Equal(t, "exists", err.Error())
+
+	_, err = call("plain")
+	assert.Equal(t, codes.Unknown, status.Code(err))
+	assert.Equal(t, "Unknown", err.Error())
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	conn := newTestConn(t)
+	stream := func(val string) (string, error) {
+		cs, err := conn.NewStream(context.Background(), &testStreamDesc, testStreamMethod)
+		if err != nil {
+			return "", err
+		}
+		if err = cs.SendMsg(wrapperspb.String(val)); err != nil {
+			return "", err
+		}
+		if err = cs.CloseSend(); err != nil {
+			return "", err
+		}
+		out := &wrapperspb.StringValue{}
+		if err = cs.RecvMsg(out); err != nil {
+			return "", err
+		}
+		// 流正常结束时 io.EOF 保持不变
+		assert.Equal(t, io.EOF, cs.RecvMsg(&wrapperspb.StringValue{}))
+		return out.Value, nil
+	}
+
+	out, err := stream("hi")
+	assert.NoError(t, err)
+	assert.Equal(t, "echo hi", out)
+
+	_, err = stream("irr")
+	assert.True(t, testCodeUserNotFound.Matches(err), err)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = stream("panic")
+	assert.Equal(t, codes.Unknown, status.Code(err))
+	assert.NotContains(t, err.Error(), "panic recovered")
+}
@@ -0,0 +1,370 @@
+// Package irrgrpc converts IRR error chains to and from gRPC statuses.
+//
+// It's a separate module, so that the core irr module does not depend on gRPC.
+// The nearest code of an error chain is mapped to a gRPC code by a Converter,
+// and the code is carried in an errdetails.ErrorInfo detail, so that the client
+// can restore an error with the original code. Internal messages, tags and the
+// cause chain are only sent when the Converter opts in.
+package irrgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// Converter converts error chains to statuses and back, the zero value is ready to use.
+	Converter struct {
+		// Domain is the domain of the ErrorInfo detail, DefaultDomain when it's empty.
+		// Only details of the same domain are restored by FromStatus.
+		Domain string
+		// Codes overrides the gRPC code of codes.
+		Codes map[irc.Code]codes.Code
+		// CodeFunc maps codes that are not in Codes, DefaultCode is used when it's nil.
+		CodeFunc func(code irc.Code) codes.Code
+		// PublicTags are the tag keys sent as ErrorInfo metadata,
+		// tags are internal and not sent by default.
+		PublicTags []string
+		// ExposeDetail uses err.Error() as the status message. By default the message
+		// is the public message of the chain (see irr.PublicMessage), the registered
+		// message of the code, or the name of the gRPC code, so that internal
+		// messages don't reach the peer.
+		ExposeDetail bool
+		// IncludeChain adds the JSON encoded cause chain, internal messages and
+		// traces included, to the detail, so that FromStatus restores the whole
+		// chain. Secrets are still masked. Enable it for trusted peers only.
+		IncludeChain bool
+	}
+
+	// StatusIrr is an IRR error restored from a status. It keeps the status, so
+	// that status.FromError and status.Code keep working on it.
+	StatusIrr struct {
+		irr.IRR
+		st *status.Status
+	}
+)
+
+const (
+	// DefaultDomain is the ErrorInfo domain used when Converter.Domain is empty.
+	DefaultDomain = "irr"
+
+	// MetadataCode is the ErrorInfo metadata key of the code.
+	MetadataCode = "code"
+	// MetadataChain is the ErrorInfo metadata key of the JSON encoded cause chain.
+	MetadataChain = "chain"
+	// MetadataTagPrefix is the prefix of the ErrorInfo metadata keys of the tags.
+	MetadataTagPrefix = "tag."
+)
+
+var (
+	// DefaultConverter is used by the package level functions and the interceptors created with nil.
+	DefaultConverter = &Converter{}
+
+	_ irr.IRR        = (*StatusIrr)(nil)
+	_ fmt.Formatter  = (*StatusIrr)(nil)
+	_ slog.LogValuer = (*StatusIrr)(nil)
+)
+
+// DefaultCode maps a code to a gRPC code. The registered GRPCCode of the code is
+// used first, then the registered HTTPStatus, or the code itself when it's in
+// [400, 599], is mapped as an HTTP status. All the others are codes.Unknown.
+func DefaultCode(code irc.Code) codes.Code {
+	httpStatus := int(code)
+	if meta, ok := code.Meta(); ok {
+		if meta.GRPCCode != 0 {
+			return codes.Code(meta.GRPCCode)
+		}
+		if meta.HTTPStatus != 0 {
+			httpStatus = meta.HTTPStatus
+		}
+	}
+	return codeOfHTTPStatus(httpStatus)
+}
+
+// codeOfHTTPStatus follows the mapping of google.rpc.Code.
+func codeOfHTTPStatus(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusRequestedRangeNotSatisfiable:
+		return codes.OutOfRange
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	switch {
+	case httpStatus >= 400 && httpStatus < 500:
+		return codes.FailedPrecondition
+	case httpStatus >= 500 && httpStatus < 600:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// Code returns the gRPC code of the code.
+func (c *Converter) Code(code irc.Code) codes.Code {
+	if grpcCode, ok := c.Codes[code]; ok {
+		return grpcCode
+	}
+	if c.CodeFunc != nil {
+		return c.CodeFunc(code)
+	}
+	return DefaultCode(code)
+}
+
+// Status converts an error chain into a status, see ExposeDetail for the message.
+// The gRPC code is mapped from the nearest code, chains without codes keep the
+// code of a status error in the chain, and context errors are mapped to
+// codes.DeadlineExceeded and codes.Canceled. It returns an OK status when err is nil.
+func (c *Converter) Status(err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+	code := irc.Code(irr.NearestCodeOf(err))
+	grpcCode := c.grpcCodeOf(err, code)
+	st := status.New(grpcCode, c.message(err, code, grpcCode))
+
+	var ir irr.IRR
+	if !errors.As(err, &ir) {
+		return st
+	}
+	info := &errdetails.ErrorInfo{
+		Reason:   reasonOf(code),
+		Domain:   c.domain(),
+		Metadata: c.publicTags(err),
+	}
+	if code != 0 {
+		info.Metadata[MetadataCode] = strconv.FormatInt(int64(code), 10)
+	}
+	if c.IncludeChain {
+		if chain, mErr := irr.MarshalErrorJSON(err); mErr == nil {
+			info.Metadata[MetadataChain] = string(chain)
+		}
+	}
+	if withDetails, dErr := st.WithDetails(info); dErr == nil {
+		return withDetails
+	}
+	return st
+}
+
+// Err converts an error chain into a status error, nil is returned when err is nil.
+// Errors that are already status errors are returned as they are.
+func (c *Converter) Err(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(interface{ GRPCStatus() *status.Status }); ok {
+		return err
+	}
+	return c.Status(err).Err()
+}
+
+// FromStatus converts a status back into an IRR error, nil is returned for OK statuses.
+// The cause chain is restored from the ErrorInfo detail of the domain, or an
+// error with the message, the code and the tags is created when there is no chain.
+func (c *Converter) FromStatus(st *status.Status) *StatusIrr {
+	if st == nil || st.Code() == codes.OK {
+		return nil
+	}
+	info := c.findErrorInfo(st)
+	if info == nil {
+		return &StatusIrr{IRR: irr.Error(st.Message()), st: st}
+	}
+
+	if chain, ok := info.Metadata[MetadataChain]; ok {
+		restored, uErr := irr.UnmarshalErrorJSON([]byte(chain))
+		if ir, isIrr := restored.Err.(irr.IRR); uErr == nil && isIrr {
+			return &StatusIrr{IRR: ir, st: st}
+		}
+	}
+
+	ir := irr.Error(st.Message())
+	if code, pErr := strconv.ParseInt(info.Metadata[MetadataCode], 10, 64); pErr == nil && code != 0 {
+		ir.SetCode(code)
+	}
+	keys := make([]string, 0, len(info.Metadata))
+	for key := range info.Metadata {
+		if strings.HasPrefix(key, MetadataTagPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ir.SetTag(strings.TrimPrefix(key, MetadataTagPrefix), info.Metadata[key])
+	}
+	return &StatusIrr{IRR: ir, st: st}
+}
+
+// FromError converts a status error back into an IRR error, nil is returned when err is nil.
+// Errors without a status, such as io.EOF, are returned as they are.
+func (c *Converter) FromError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*StatusIrr); ok {
+		return err
+	}
+	gs, ok := err.(interface{ GRPCStatus() *status.Status })
+	if !ok {
+		return err
+	}
+	if restored := c.FromStatus(gs.GRPCStatus()); restored != nil {
+		return restored
+	}
+	return err
+}
+
+// ToStatus converts an error chain into a status with the DefaultConverter.
+func ToStatus(err error) *status.Status {
+	return DefaultConverter.Status(err)
+}
+
+// FromStatus converts a status back into an IRR error with the DefaultConverter.
+func FromStatus(st *status.Status) *StatusIrr {
+	return DefaultConverter.FromStatus(st)
+}
+
+// FromError converts a status error back into an IRR error with the DefaultConverter.
+func FromError(err error) error {
+	return DefaultConverter.FromError(err)
+}
+
+// GRPCStatus returns the status the error is restored from, it's used by status.FromError.
+func (e *StatusIrr) GRPCStatus() *status.Status {
+	return e.st
+}
+
+// Unwrap returns the restored IRR error, so that errors.As can find it.
+func (e *StatusIrr) Unwrap() error {
+	return e.IRR
+}
+
+// Format
+// the implementation of fmt.Formatter, it's delegated to the restored error
+func (e *StatusIrr) Format(s fmt.State, verb rune) {
+	if f, ok := e.IRR.(fmt.Formatter); ok {
+		f.Format(s, verb)
+		return
+	}
+	_, _ = fmt.Fprintf(s, fmt.FormatString(s, verb), e.IRR)
+}
+
+// LogValue
+// the implementation of slog.LogValuer, it's delegated to the restored error
+func (e *StatusIrr) LogValue() slog.Value {
+	if v, ok := e.IRR.(slog.LogValuer); ok {
+		return v.LogValue()
+	}
+	return slog.StringValue(e.Error())
+}
+
+func (c *Converter) domain() string {
+	if c.Domain != "" {
+		return c.Domain
+	}
+	return DefaultDomain
+}
+
+// message returns err.Error() when ExposeDetail is set, otherwise the public
+// message, the registered message of code, or the name of grpcCode.
+func (c *Converter) message(err error, code irc.Code, grpcCode codes.Code) string {
+	if c.ExposeDetail {
+		return err.Error()
+	}
+	if public := irr.PublicMessage(err); public != "" {
+		return public
+	}
+	if meta, ok := code.Meta(); ok && meta.Message != "" {
+		return meta.Message
+	}
+	return grpcCode.String()
+}
+
+func (c *Converter) grpcCodeOf(err error, code irc.Code) codes.Code {
+	if code != 0 {
+		return c.Code(code)
+	}
+	var gs interface{ GRPCStatus() *status.Status }
+	switch {
+	case errors.As(err, &gs) && gs.GRPCStatus() != nil:
+		return gs.GRPCStatus().Code()
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	}
+	return codes.Unknown
+}
+
+func (c *Converter) findErrorInfo(st *status.Status) *errdetails.ErrorInfo {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetDomain() == c.domain() {
+			return info
+		}
+	}
+	return nil
+}
+
+// reasonOf returns the registered name of the code, or CODE_<code> when it's not registered.
+func reasonOf(code irc.Code) string {
+	if code == 0 {
+		return "UNKNOWN"
+	}
+	if meta, ok := code.Meta(); ok {
+		return meta.Name
+	}
+	return "CODE_" + strconv.FormatInt(int64(code), 10)
+}
+
+// publicTags collects the allowed tags of the chain as metadata, the nearest value wins.
+// Values of sensitive keys (see irr.SetSensitiveKeys) are masked even when they are allowed.
+func (c *Converter) publicTags(err error) map[string]string {
+	metadata := make(map[string]string)
+	if len(c.PublicTags) == 0 {
+		return metadata
+	}
+	irr.Walk(err, func(err error) bool {
+		tagger, ok := err.(irr.ITagger)
+		if !ok {
+			return true
+		}
+		for _, key := range c.PublicTags {
+			metaKey := MetadataTagPrefix + key
+			if _, exist := metadata[metaKey]; exist {
+				continue
+			}
+			if values := tagger.GetTag(key); len(values) > 0 {
+				metadata[metaKey] = irr.Tag{Key: key, Value: values[0]}.Redacted().Value
+			}
+		}
+		return true
+	})
+	return metadata
+}
@@ -0,0 +1,187 @@
+package irrgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testCodeUserNotFound irc.Code = 93001
+	testCodeQuota        irc.Code = 93002
+	testCodeUnregistered irc.Code = 93999
+)
+
+func init() {
+	irc.MustRegister(irc.Meta{Code: testCodeUserNotFound, Name: "TEST_USER_NOT_FOUND", GRPCCode: uint32(codes.NotFound)})
+	irc.MustRegister(irc.Meta{Code: testCodeQuota, Name: "TEST_QUOTA", Message: "quota exceeded", HTTPStatus: http.StatusTooManyRequests})
+}
+
+func errorInfoOf(t *testing.T, st *status.Status) *errdetails.ErrorInfo {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	t.Fatalf("no ErrorInfo in %v", st)
+	return nil
+}
+
+func TestDefaultCode(t *testing.T) {
+	assert.Equal(t, codes.NotFound, DefaultCode(testCodeUserNotFound))
+	assert.Equal(t, codes.ResourceExhausted, DefaultCode(testCodeQuota))
+	assert.Equal(t, codes.Unauthenticated, DefaultCode(401))
+	assert.Equal(t, codes.FailedPrecondition, DefaultCode(418))
+	assert.Equal(t, codes.Unavailable, DefaultCode(503))
+	assert.Equal(t, codes.Internal, DefaultCode(500))
+	assert.Equal(t, codes.Unknown, DefaultCode(testCodeUnregistered))
+}
+
+func TestConverter_Code(t *testing.T) {
+	c := &Converter{Codes: map[irc.Code]codes.Code{testCodeUserNotFound: codes.InvalidArgument}}
+	assert.Equal(t, codes.InvalidArgument, c.Code(testCodeUserNotFound))
+	assert.Equal(t, codes.ResourceExhausted, c.Code(testCodeQuota))
+
+	c.CodeFunc = func(code irc.Code) codes.Code { return codes.Aborted }
+	assert.Equal(t, codes.Aborted, c.Code(testCodeQuota))
+}
+
+func TestConverter_Status(t *testing.T) {
+	inner := testCodeUserNotFound.Error("user %s not found", "u1")
+	inner.SetTag("user", "u1")
+	outer := irr.Wrap(inner, "get profile")
+	outer.SetTag("user", "outer")
+	outer.SetTag("op", "get")
+
+	st := ToStatus(outer)
+	assert.Equal(t, codes.NotFound, st.Code())
+	// 默认不发送内部消息、标签与错误链
+	assert.Equal(t, "NotFound", st.Message())
+
+	info := errorInfoOf(t, st)
+	assert.Equal(t, "TEST_USER_NOT_FOUND", info.Reason)
+	assert.Equal(t, DefaultDomain, info.Domain)
+	assert.Equal(t, map[string]string{MetadataCode: "93001"}, info.Metadata)
+
+	// 只发送允许的标签，最近的值优先
+	info = errorInfoOf(t, (&Converter{PublicTags: []string{"user", "missing"}}).Status(outer))
+	assert.Equal(t, map[string]string{MetadataCode: "93001", "tag.user": "outer"}, info.Metadata)
+
+	// 可信的对端可以选择发送内部消息与错误链
+	st = (&Converter{ExposeDetail: true, IncludeChain: true}).Status(outer)
+	assert.Equal(t, outer.Error(), st.Message())
+	assert.Contains(t, errorInfoOf(t, st).Metadata[MetadataChain], `"msg":"get profile"`)
+
+	st = (&Converter{Domain: "example.com"}).Status(testCodeUnregistered.Error("x"))
+	info = errorInfoOf(t, st)
+	assert.Equal(t, "CODE_93999", info.Reason)
+	assert.Equal(t, "example.com", info.Domain)
+
+	assert.Equal(t, codes.OK, ToStatus(nil).Code())
+	// 非 IRR 错误不附带 ErrorInfo
+	st = ToStatus(errors.New("plain"))
+	assert.Equal(t, codes.Unknown, st.Code())
+	assert.Equal(t, "Unknown", st.Message())
+	assert.Empty(t, st.Details())
+	assert.Equal(t, codes.DeadlineExceeded, ToStatus(irr.Wrap(context.DeadlineExceeded, "call")).Code())
+	assert.Equal(t, codes.Canceled, ToStatus(fmt.Errorf("call: %w", context.Canceled)).Code())
+	// 没有错误码时保留链中 status 错误的 code
+	assert.Equal(t, codes.AlreadyExists, ToStatus(irr.Wrap(status.Error(codes.AlreadyExists, "exists"), "create")).Code())
+}
+
+func TestConverter_StatusMessage(t *testing.T) {
+	inner := testCodeUserNotFound.Error("select users: no rows")
+	inner.SetPublicMsg("the user does not exist")
+	assert.Equal(t, "the user does not exist", ToStatus(irr.Wrap(inner, "get profile")).Message())
+
+	// 没有公开消息时使用注册的消息
+	assert.Equal(t, "quota exceeded", ToStatus(testCodeQuota.Error("bucket b1 is full")).Message())
+}
+
+func TestConverter_StatusRedaction(t *testing.T) {
+	irr.SetSensitiveKeys("email")
+	defer irr.SetSensitiveKeys()
+
+	err := testCodeUserNotFound.Error("user %s not found", irr.Secret("alice@example.com"))
+	err.SetTag("email", "alice@example.com")
+	st := (&Converter{ExposeDetail: true, IncludeChain: true, PublicTags: []string{"email"}}).Status(err)
+	assert.Equal(t, "code(93001), user *** not found[email:***] ", st.Message())
+	info := errorInfoOf(t, st)
+	assert.Equal(t, "***", info.Metadata["tag.email"])
+	assert.NotContains(t, info.Metadata[MetadataChain], "alice")
+}
+
+func TestConverter_Err(t *testing.T) {
+	assert.Nil(t, DefaultConverter.Err(nil))
+	statusErr := status.Error(codes.Aborted, "aborted")
+	assert.Equal(t, statusErr, DefaultConverter.Err(statusErr))
+	assert.Equal(t, codes.NotFound, status.Code(DefaultConverter.Err(testCodeUserNotFound.Error("x"))))
+}
+
+func TestFromStatus_RoundTrip(t *testing.T) {
+	inner := testCodeUserNotFound.Error("user not found")
+	inner.SetTag("user", "u1")
+	outer := irr.Wrap(inner, "get profile")
+
+	c := &Converter{ExposeDetail: true, IncludeChain: true}
+	restored := c.FromStatus(c.Status(outer))
+	assert.Equal(t, outer.Error(), restored.Error())
+	assert.Equal(t, int64(testCodeUserNotFound), restored.NearestCode())
+	assert.True(t, testCodeUserNotFound.Matches(restored))
+	assert.Equal(t, []string{"u1"}, errors.Unwrap(restored.IRR).(irr.IRR).GetTag("user"))
+
+	// status.FromError 与 status.Code 依然可用
+	assert.Equal(t, codes.NotFound, status.Code(restored))
+	st, ok := status.FromError(irr.Wrap(restored, "client"))
+	assert.True(t, ok)
+	assert.Equal(t, codes.NotFound, st.Code())
+
+	var basic *irr.BasicIrr
+	assert.True(t, errors.As(restored, &basic))
+	assert.Equal(t, "get profile", basic.Msg)
+	assert.Contains(t, fmt.Sprintf("%+v", restored), "get profile")
+}
+
+func TestFromStatus_WithoutChain(t *testing.T) {
+	err := testCodeQuota.Error("quota exceeded")
+	err.SetTag("b", "2")
+	err.SetTag("a", "1")
+
+	c := &Converter{PublicTags: []string{"b", "a"}}
+	restored := c.FromStatus(c.Status(err))
+	assert.Equal(t, int64(testCodeQuota), restored.NearestCode())
+	assert.Equal(t, "code(93002), quota exceeded[a:1] [b:2] ", restored.Error())
+	assert.Equal(t, []irr.Tag{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}, restored.IRR.(irr.ITagLister).AllTags())
+	assert.Equal(t, codes.ResourceExhausted, restored.GRPCStatus().Code())
+
+	// 其他 domain 的 detail 不会被还原
+	restored = (&Converter{Domain: "other"}).FromStatus(ToStatus(err))
+	assert.False(t, restored.HasAnyCode())
+	assert.Equal(t, "quota exceeded", restored.Error())
+
+	restored = FromStatus(status.New(codes.Unavailable, "unavailable"))
+	assert.Equal(t, "unavailable", restored.Error())
+	assert.Equal(t, codes.Unavailable, status.Code(restored))
+
+	assert.Nil(t, FromStatus(nil))
+	assert.Nil(t, FromStatus(status.New(codes.OK, "")))
+}
+
+func TestFromError(t *testing.T) {
+	assert.Nil(t, FromError(nil))
+	plain := errors.New("io")
+	assert.Equal(t, plain, FromError(plain))
+
+	restored := FromError(ToStatus(testCodeUserNotFound.Error("x")).Err())
+	assert.IsType(t, &StatusIrr{}, restored)
+	assert.Equal(t, restored, FromError(restored))
+}
@@ -0,0 +1,219 @@
+package irrhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+)
+
+// Encoder converts error chains into problems, the zero value is ready to use.
+type Encoder struct {
+	// Statuses overrides the HTTP status of codes.
+	Statuses map[irc.Code]int
+	// StatusFunc maps codes that are not in Statuses, DefaultStatus is used when it's nil.
+	StatusFunc func(code irc.Code) int
+	// PublicTags are the tag keys exposed in the "tags" extension member,
+	// tags are internal and not exposed by default.
+	PublicTags []string
+	// TypeBase is used to build the type of codes without a registered docs url,
+	// as TypeBase + the code name. The type is "about:blank" when it's empty.
+	TypeBase string
+	// ExposeDetail uses the joined internal messages of the chain as the detail
+	// when it has no public message. By default the detail is the nearest public
+	// message (see irr.PublicMessage), or the title, so that internal messages
+	// never reach the client.
+	ExposeDetail bool
+}
+
+// DefaultEncoder is used by WriteError.
+var DefaultEncoder = &Encoder{}
+
+// DefaultStatus maps a code to an HTTP status. The registered HTTPStatus of the code
+// is used first, then codes in [400, 599] are regarded as HTTP statuses themselves,
+// and all the others, including errors without codes, are 500.
+func DefaultStatus(code irc.Code) int {
+	if meta, ok := code.Meta(); ok && meta.HTTPStatus != 0 {
+		return meta.HTTPStatus
+	}
+	if code >= 400 && code <= 599 {
+		return int(code)
+	}
+	return http.StatusInternalServerError
+}
+
+// Status returns the HTTP status of the code.
+func (e *Encoder) Status(code irc.Code) int {
+	if status, ok := e.Statuses[code]; ok {
+		return status
+	}
+	if e.StatusFunc != nil {
+		return e.StatusFunc(code)
+	}
+	return DefaultStatus(code)
+}
+
+// Problem converts an error chain into a problem, r is used to fill the instance
+// and can be nil. It returns nil when err is nil.
+func (e *Encoder) Problem(r *http.Request, err error) *Problem {
+	if err == nil {
+		return nil
+	}
+	code := irc.Code(irr.NearestCodeOf(err))
+	status := e.Status(code)
+
+	p := &Problem{
+		Type:   BlankType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   int64(code),
+		Tags:   e.publicTags(err),
+	}
+	if meta, ok := code.Meta(); ok {
+		if meta.Message != "" {
+			p.Title = meta.Message
+		}
+		if meta.DocsURL != "" {
+			p.Type = meta.DocsURL
+		}
+	}
+	p.Detail = e.detail(err, p.Title)
+	if p.Type == BlankType && e.TypeBase != "" && code != 0 {
+		p.Type = e.TypeBase + code.String()
+	}
+	if r != nil && r.URL != nil {
+		p.Instance = r.URL.RequestURI()
+	}
+	return p
+}
+
+// Write writes the problem of err as the response, nothing is written when err is nil.
+// The Retry-After header is set when the chain has a retry-after hint (see irr.RetryAfterOf).
+func (e *Encoder) Write(w http.ResponseWriter, r *http.Request, err error) {
+	if p := e.Problem(r, err); p != nil {
+		writeProblem(w, p, err)
+	}
+}
+
+// WriteError writes the problem of err as the response with the DefaultEncoder.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	DefaultEncoder.Write(w, r, err)
+}
+
+func writeProblem(w http.ResponseWriter, p *Problem, err error) {
+	data, mErr := json.Marshal(p)
+	if mErr != nil {
+		// 扩展字段无法编码时退化为只包含标准字段的响应
+		p.Extensions = nil
+		data, _ = json.Marshal(p)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if d, ok := irr.RetryAfterOf(err); ok {
+		// Retry-After 以秒为单位，向上取整
+		w.Header().Set("Retry-After", strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10))
+	}
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(data)
+}
+
+// publicTags collects the allowed tags of the chain, the nearest value wins.
+// Values of sensitive keys (see irr.SetSensitiveKeys) are masked even when they are allowed.
+func (e *Encoder) publicTags(err error) map[string]string {
+	if len(e.PublicTags) == 0 {
+		return nil
+	}
+	var tags map[string]string
+	irr.Walk(err, func(err error) bool {
+		tagger, ok := err.(irr.ITagger)
+		if !ok {
+			return true
+		}
+		for _, key := range e.PublicTags {
+			if _, exist := tags[key]; exist {
+				continue
+			}
+			if values := tagger.GetTag(key); len(values) > 0 {
+				if tags == nil {
+					tags = make(map[string]string, len(e.PublicTags))
+				}
+				tags[key] = irr.Tag{Key: key, Value: values[0]}.Redacted().Value
+			}
+		}
+		return true
+	})
+	return tags
+}
+
+// detail returns the nearest public message. Without one, it's the joined
+// messages of the chain when ExposeDetail is set, or the title.
+func (e *Encoder) detail(err error, title string) string {
+	if public := irr.PublicMessage(err); public != "" {
+		return public
+	}
+	if e.ExposeDetail {
+		return detailOf(err)
+	}
+	return title
+}
+
+// detailOf joins the messages of the chain, codes, tags, attrs and traces are
+// internal and left out. Errors other than IRR errors render the rest of the
+// chain with their Error method.
+func detailOf(err error) string {
+	w := &detailWriter{}
+	w.writeChain(err)
+	return w.sb.String()
+}
+
+// detailWriter writes messages separated by ", ", and branches as {a; b}.
+type detailWriter struct {
+	sb      strings.Builder
+	needSep bool
+}
+
+func (w *detailWriter) writeChain(err error) {
+	for err != nil {
+		msg, next := "", error(nil)
+		switch x := err.(type) {
+		case *irr.BasicIrr:
+			msg, next = x.Msg, x.Unwrap()
+		case *irr.ContextualIrr:
+			msg, next = x.Msg, x.Unwrap()
+		case interface{ Unwrap() []error }:
+			w.writeBranches(x.Unwrap())
+			return
+		default:
+			msg = err.Error()
+		}
+		if msg != "" {
+			w.write(msg)
+		}
+		err = next
+	}
+}
+
+func (w *detailWriter) writeBranches(branches []error) {
+	w.write("{")
+	for i, branch := range branches {
+		if i > 0 {
+			w.sb.WriteString("; ")
+		}
+		w.needSep = false
+		w.writeChain(branch)
+	}
+	w.sb.WriteRune('}')
+	w.needSep = true
+}
+
+func (w *detailWriter) write(msg string) {
+	if w.needSep {
+		w.sb.WriteString(", ")
+	}
+	w.sb.WriteString(msg)
+	w.needSep = true
+}
@@ -0,0 +1,166 @@
+package irrhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testCodeUserNotFound irc.Code = 92001
+	testCodeRateLimited  irc.Code = 92002
+	testCodeUnregistered irc.Code = 92999
+)
+
+func init() {
+	irc.MustRegister(irc.Meta{
+		Code:       testCodeUserNotFound,
+		Name:       "TEST_USER_NOT_FOUND",
+		Message:    "user not found",
+		HTTPStatus: http.StatusNotFound,
+		DocsURL:    "https://docs.example.com/errors/TEST_USER_NOT_FOUND",
+	})
+	irc.MustRegister(irc.Meta{Code: testCodeRateLimited, Name: "TEST_RATE_LIMITED", HTTPStatus: http.StatusTooManyRequests})
+}
+
+func TestDefaultStatus(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, DefaultStatus(testCodeUserNotFound))
+	assert.Equal(t, http.StatusConflict, DefaultStatus(409))
+	assert.Equal(t, http.StatusInternalServerError, DefaultStatus(testCodeUnregistered))
+	assert.Equal(t, http.StatusInternalServerError, DefaultStatus(0))
+}
+
+func TestEncoder_Status(t *testing.T) {
+	e := &Encoder{Statuses: map[irc.Code]int{testCodeUserNotFound: http.StatusGone}}
+	assert.Equal(t, http.StatusGone, e.Status(testCodeUserNotFound))
+	assert.Equal(t, http.StatusTooManyRequests, e.Status(testCodeRateLimited))
+
+	e.StatusFunc = func(code irc.Code) int { return http.StatusBadGateway }
+	assert.Equal(t, http.StatusGone, e.Status(testCodeUserNotFound))
+	assert.Equal(t, http.StatusBadGateway, e.Status(testCodeRateLimited))
+}
+
+func TestEncoder_Problem(t *testing.T) {
+	inner := testCodeUserNotFound.Error("user %s not found", "u1")
+	inner.SetTag("user", "u1")
+	inner.SetTag("sql", "select * from users")
+	outer := irr.Wrap(inner, "get profile")
+	outer.SetTag("user", "outer")
+
+	r := httptest.NewRequest(http.MethodGet, "/users/u1?verbose=1", nil)
+	e := &Encoder{PublicTags: []string{"user", "missing"}, ExposeDetail: true}
+	p := e.Problem(r, outer)
+	assert.Equal(t, &Problem{
+		Type:     "https://docs.example.com/errors/TEST_USER_NOT_FOUND",
+		Title:    "user not found",
+		Status:   http.StatusNotFound,
+		Detail:   "get profile, user u1 not found",
+		Instance: "/users/u1?verbose=1",
+		Code:     int64(testCodeUserNotFound),
+		Tags:     map[string]string{"user": "outer"},
+	}, p)
+
+	// detail 只包含错误消息，不包含错误码与 tag
+	p = e.Problem(nil, inner)
+	assert.Equal(t, "user u1 not found", p.Detail)
+	assert.Equal(t, "", p.Instance)
+
+	// 任意错误链都可以转换
+	p = (&Encoder{TypeBase: "https://errors.example.com/"}).Problem(nil, fmt.Errorf("call: %w", irr.Wrap(testCodeRateLimited.Error("slow down"), "retry")))
+	assert.Equal(t, int64(testCodeRateLimited), p.Code)
+	assert.Equal(t, http.StatusTooManyRequests, p.Status)
+	assert.Equal(t, "Too Many Requests", p.Title)
+	assert.Equal(t, "https://errors.example.com/TEST_RATE_LIMITED", p.Type)
+	assert.Nil(t, p.Tags)
+
+	p = DefaultEncoder.Problem(nil, errors.New("boom"))
+	assert.Equal(t, &Problem{Type: BlankType, Title: "Internal Server Error", Status: 500, Detail: "Internal Server Error"}, p)
+
+	assert.Nil(t, DefaultEncoder.Problem(nil, nil))
+}
+
+func TestEncoder_PublicTagsOfJoinedErrors(t *testing.T) {
+	a, b := irr.Error("a"), irr.Error("b")
+	a.SetTag("k", "a")
+	b.SetTag("k", "b")
+	b.SetTag("other", "b")
+	p := (&Encoder{PublicTags: []string{"k", "other"}, ExposeDetail: true}).Problem(nil, irr.Wrap(irr.Join(a, irr.Wrap(b, ""), errors.New("c")), "joined"))
+	assert.Equal(t, map[string]string{"k": "a", "other": "b"}, p.Tags)
+	assert.Equal(t, "joined, {a; b; c}", p.Detail)
+	// 非 IRR 错误通过 Error 输出后续的错误链
+	assert.Equal(t, "call: x, std", detailOf(fmt.Errorf("call: %w", irr.Wrap(errors.New("std"), "x"))))
+}
+
+func TestEncoder_PublicMessage(t *testing.T) {
+	inner := testCodeUserNotFound.Error("no rows in users, replica-3")
+	inner.SetPublicMsg("the user does not exist")
+	outer := irr.Wrap(inner, "load profile")
+
+	assert.Equal(t, "the user does not exist", DefaultEncoder.Problem(nil, outer).Detail)
+	assert.Equal(t, "the user does not exist", (&Encoder{ExposeDetail: true}).Problem(nil, outer).Detail)
+
+	// 没有公开消息时默认不输出任何内部消息，detail 与 title 相同
+	plain := irr.Wrap(testCodeUserNotFound.Error("no rows"), "load profile")
+	p := DefaultEncoder.Problem(nil, plain)
+	assert.Equal(t, "user not found", p.Detail)
+	assert.Equal(t, "user not found", p.Title)
+	assert.Equal(t, "Too Many Requests", DefaultEncoder.Problem(nil, testCodeRateLimited.Error("bucket b1 is full")).Detail)
+	assert.Equal(t, "load profile, no rows", (&Encoder{ExposeDetail: true}).Problem(nil, plain).Detail)
+}
+
+func TestEncoder_Redaction(t *testing.T) {
+	irr.SetSensitiveKeys("email")
+	defer irr.SetSensitiveKeys()
+
+	err := testCodeUserNotFound.Error("user %s not found", irr.Secret("alice@example.com"))
+	err.SetTag("email", "alice@example.com")
+	p := (&Encoder{PublicTags: []string{"email"}, ExposeDetail: true}).Problem(nil, err)
+	// 即使被列为公开的 tag，敏感的值也只输出掩码
+	assert.Equal(t, map[string]string{"email": "***"}, p.Tags)
+	assert.Equal(t, "user *** not found", p.Detail)
+}
+
+func TestWriteError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteError(rec, httptest.NewRequest(http.MethodPost, "/orders", nil), testCodeUserNotFound.Error("not found"))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	body := map[string]any{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, map[string]any{
+		"type":     "https://docs.example.com/errors/TEST_USER_NOT_FOUND",
+		"title":    "user not found",
+		"status":   float64(404),
+		"detail":   "user not found",
+		"instance": "/orders",
+		"code":     float64(testCodeUserNotFound),
+	}, body)
+
+	rec = httptest.NewRecorder()
+	WriteError(rec, nil, nil)
+	assert.Equal(t, 0, rec.Body.Len())
+}
+
+func TestWriteError_RetryAfter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := testCodeRateLimited.Error("slow down")
+	err.SetRetryAfter(1500 * time.Millisecond)
+	WriteError(rec, nil, irr.Wrap(err, "call api"))
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	// 向上取整为 2 秒
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+
+	rec = httptest.NewRecorder()
+	WriteError(rec, nil, testCodeRateLimited.Error("slow down"))
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+}
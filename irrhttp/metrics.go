@@ -0,0 +1,87 @@
+package irrhttp
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// Metrics counts the requests and errors handled by a Middleware, the zero
+	// value is ready to use and a nil *Metrics counts nothing.
+	Metrics struct {
+		requests atomic.Int64
+		errors   atomic.Int64
+		panics   atomic.Int64
+
+		mu       sync.Mutex
+		codes    map[int64]int64
+		statuses map[int]int64
+	}
+
+	// MetricsSnapshot is a copy of the counters of Metrics.
+	MetricsSnapshot struct {
+		Requests    int64           `json:"requests"`
+		Errors      int64           `json:"errors"`
+		Panics      int64           `json:"panics"`
+		CodeStats   map[int64]int64 `json:"code_stats"`
+		StatusStats map[int]int64   `json:"status_stats"`
+	}
+)
+
+// Snapshot returns a copy of the counters.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := MetricsSnapshot{
+		Requests:    m.requests.Load(),
+		Errors:      m.errors.Load(),
+		Panics:      m.panics.Load(),
+		CodeStats:   make(map[int64]int64, len(m.codes)),
+		StatusStats: make(map[int]int64, len(m.statuses)),
+	}
+	for code, count := range m.codes {
+		snapshot.CodeStats[code] = count
+	}
+	for status, count := range m.statuses {
+		snapshot.StatusStats[status] = count
+	}
+	return snapshot
+}
+
+// Reset clears all the counters.
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests.Store(0)
+	m.errors.Store(0)
+	m.panics.Store(0)
+	m.codes = nil
+	m.statuses = nil
+}
+
+func (m *Metrics) recordRequest() {
+	if m != nil {
+		m.requests.Add(1)
+	}
+}
+
+func (m *Metrics) recordError(code int64, status int, panicked bool) {
+	if m == nil {
+		return
+	}
+	m.errors.Add(1)
+	if panicked {
+		m.panics.Add(1)
+	}
+
+	m.mu.Lock()
+	if m.codes == nil {
+		m.codes = make(map[int64]int64)
+		m.statuses = make(map[int]int64)
+	}
+	m.codes[code]++
+	m.statuses[status]++
+	m.mu.Unlock()
+}
@@ -0,0 +1,169 @@
+package irrhttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/khicago/irr"
+)
+
+type (
+	// HandlerFunc is an http handler that returns an error, the error is written as
+	// a problem by the Middleware in the request context, or the DefaultMiddleware.
+	HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+	// Middleware turns errors and panics of handlers into problem responses, the
+	// zero value is ready to use.
+	//
+	// Each error is wrapped by a layer with the request method, path and request id
+	// as tags, panics are recovered into errors with the full call stack. The error
+	// is counted in Metrics and passed to OnError before it's written, it's not
+	// written when the handler has already written the response header.
+	Middleware struct {
+		// Encoder converts errors to problems, DefaultEncoder is used when it's nil.
+		Encoder *Encoder
+		// RequestIDHeader is the header of the request id, "X-Request-Id" by default.
+		RequestIDHeader string
+		// Metrics counts requests and errors, nothing is counted when it's nil.
+		Metrics *Metrics
+		// OnError is called with each error, it's usually used for logging.
+		OnError func(r *http.Request, err irr.IRR)
+	}
+
+	middlewareCtxKey struct{}
+
+	// middlewareCtx is stored in the request context by Middleware.Handler. The
+	// writer is shared through the context rather than found by a type assertion,
+	// since handlers in between may wrap the writer with their own.
+	middlewareCtx struct {
+		m  *Middleware
+		rw *responseWriter
+	}
+
+	// responseWriter records whether the response header has been written.
+	responseWriter struct {
+		http.ResponseWriter
+		wroteHeader bool
+	}
+)
+
+const (
+	TagMethod    = "http.method"
+	TagPath      = "http.path"
+	TagRequestID = "request_id"
+
+	DefaultRequestIDHeader = "X-Request-Id"
+)
+
+// DefaultMiddleware is used by HandlerFunc when there is no Middleware in the request context.
+var DefaultMiddleware = &Middleware{}
+
+// Handler returns a handler which recovers the panics of next. The middleware is
+// stored in the request context, so that HandlerFunc handlers under next, for
+// example the ones registered in a http.ServeMux, report errors to it.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+		r = r.WithContext(context.WithValue(r.Context(), middlewareCtxKey{}, &middlewareCtx{m: m, rw: rw}))
+		m.serve(rw, r, func(w http.ResponseWriter, r *http.Request) error {
+			next.ServeHTTP(w, r)
+			return nil
+		})
+	})
+}
+
+// HandlerFunc returns a handler which writes the error or the panic of fn.
+func (m *Middleware) HandlerFunc(fn HandlerFunc) http.Handler {
+	return m.Handler(fn)
+}
+
+// ServeHTTP
+// the implementation of http.Handler, the panics are recovered by the Middleware
+// in the request context, or the DefaultMiddleware when there is none
+func (fn HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mc, ok := r.Context().Value(middlewareCtxKey{}).(*middlewareCtx)
+	if !ok {
+		DefaultMiddleware.serve(&responseWriter{ResponseWriter: w}, r, fn)
+		return
+	}
+	if err := fn(w, r); err != nil {
+		mc.m.fail(w, mc.rw, r, irr.Wrap(err, "%s %s", r.Method, r.URL.Path), false)
+	}
+}
+
+func (m *Middleware) serve(rw *responseWriter, r *http.Request, fn HandlerFunc) {
+	m.Metrics.recordRequest()
+
+	err, panicked := m.call(rw, r, fn)
+	if err == nil {
+		return
+	}
+	if !panicked {
+		err = irr.Wrap(err, "%s %s", r.Method, r.URL.Path)
+	}
+	m.fail(rw, rw, r, err.(irr.IRR), panicked)
+}
+
+// call runs fn and recovers its panic into an error with the full call stack,
+// http.ErrAbortHandler is re-panicked to abort the response as net/http does.
+func (m *Middleware) call(w http.ResponseWriter, r *http.Request, fn HandlerFunc) (err error, panicked bool) {
+	defer irr.CatchFailure(func(e error) {
+		if e == nil {
+			return
+		}
+		if e == http.ErrAbortHandler {
+			panic(e)
+		}
+		err, panicked = irr.TrackFull(e, "panic recovered, %s %s", r.Method, r.URL.Path), true
+	})
+	return fn(w, r), false
+}
+
+// fail writes the problem of err to w, unless the response header has already
+// been written through rw, the writer wrapped by the middleware.
+func (m *Middleware) fail(w http.ResponseWriter, rw *responseWriter, r *http.Request, err irr.IRR, panicked bool) {
+	err.SetTag(TagMethod, r.Method)
+	err.SetTag(TagPath, r.URL.Path)
+	if id := r.Header.Get(m.requestIDHeader()); id != "" {
+		err.SetTag(TagRequestID, id)
+	}
+
+	p := m.encoder().Problem(r, err)
+	m.Metrics.recordError(p.Code, p.Status, panicked)
+	if m.OnError != nil {
+		m.OnError(r, err)
+	}
+	if rw.wroteHeader {
+		return
+	}
+	writeProblem(w, p, err)
+}
+
+func (m *Middleware) encoder() *Encoder {
+	if m.Encoder != nil {
+		return m.Encoder
+	}
+	return DefaultEncoder
+}
+
+func (m *Middleware) requestIDHeader() string {
+	if m.RequestIDHeader != "" {
+		return m.RequestIDHeader
+	}
+	return DefaultRequestIDHeader
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(data)
+}
+
+// Unwrap returns the original writer for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
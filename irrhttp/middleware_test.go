@@ -0,0 +1,162 @@
+package irrhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/khicago/irr"
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) *Problem {
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	p := &Problem{}
+	if err := json.Unmarshal(rec.Body.Bytes(), p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestHandlerFunc_Standalone(t *testing.T) {
+	h := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return testCodeUserNotFound.Error("user not found")
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/u1", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	p := decodeProblem(t, rec)
+	assert.Equal(t, int64(testCodeUserNotFound), p.Code)
+	// 没有公开消息时 detail 使用注册的消息，不包含内部的请求信息
+	assert.Equal(t, "user not found", p.Detail)
+	assert.Equal(t, "/users/u1", p.Instance)
+}
+
+func TestMiddleware_Routes(t *testing.T) {
+	var got []irr.IRR
+	m := &Middleware{
+		Encoder: &Encoder{PublicTags: []string{TagRequestID}},
+		Metrics: &Metrics{},
+		OnError: func(r *http.Request, err irr.IRR) { got = append(got, err) },
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/users/", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return irr.Wrap(testCodeUserNotFound.Error("user not found"), "load user")
+	}))
+	mux.Handle("/ok", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		_, _ = w.Write([]byte("ok"))
+		return nil
+	}))
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	handler := m.Handler(mux)
+
+	req := httptest.NewRequest(http.MethodDelete, "/users/u1", nil)
+	req.Header.Set(DefaultRequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	p := decodeProblem(t, rec)
+	assert.Equal(t, map[string]string{TagRequestID: "req-1"}, p.Tags)
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, []string{http.MethodDelete}, got[0].GetTag(TagMethod))
+	assert.Equal(t, []string{"/users/u1"}, got[0].GetTag(TagPath))
+	assert.True(t, testCodeUserNotFound.Matches(got[0]))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, 2, len(got))
+	assert.True(t, errors.Is(got[1], irr.ErrUntypedExecutionFailure))
+	// panic 被恢复为带完整调用栈的错误，栈中包含 panic 的位置
+	stack := got[1].ToString(true, "\n")
+	assert.Contains(t, stack, "panic recovered, GET /panic")
+	assert.Contains(t, stack, "TestMiddleware_Routes.func")
+
+	snapshot := m.Metrics.Snapshot()
+	assert.Equal(t, int64(3), snapshot.Requests)
+	assert.Equal(t, int64(2), snapshot.Errors)
+	assert.Equal(t, int64(1), snapshot.Panics)
+	assert.Equal(t, map[int64]int64{int64(testCodeUserNotFound): 1, 0: 1}, snapshot.CodeStats)
+	assert.Equal(t, map[int]int64{http.StatusNotFound: 1, http.StatusInternalServerError: 1}, snapshot.StatusStats)
+
+	m.Metrics.Reset()
+	assert.Equal(t, MetricsSnapshot{CodeStats: map[int64]int64{}, StatusStats: map[int]int64{}}, m.Metrics.Snapshot())
+}
+
+func TestMiddleware_HandlerFunc(t *testing.T) {
+	m := &Middleware{Metrics: &Metrics{}}
+	h := m.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		panic(testCodeRateLimited.Error("too many"))
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("{}")))
+
+	// 以 error 触发的 panic 保留错误码
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, int64(testCodeRateLimited), decodeProblem(t, rec).Code)
+	assert.Equal(t, int64(1), m.Metrics.Snapshot().Panics)
+}
+
+func TestMiddleware_HeaderWritten(t *testing.T) {
+	var got irr.IRR
+	m := &Middleware{Metrics: &Metrics{}, OnError: func(r *http.Request, err irr.IRR) { got = err }}
+	h := m.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		return errors.New("late failure")
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+
+	// 已经写出响应头时只统计与回调，不再写出 problem
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, 0, rec.Body.Len())
+	assert.Equal(t, "GET /jobs, late failure", got.(*irr.BasicIrr).Msg+", "+errors.Unwrap(got).Error())
+	assert.Equal(t, int64(1), m.Metrics.Snapshot().Errors)
+}
+
+func TestMiddleware_HeaderWrittenThroughWrapper(t *testing.T) {
+	m := &Middleware{Metrics: &Metrics{}}
+	mux := http.NewServeMux()
+	mux.Handle("/jobs", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		return errors.New("late failure")
+	}))
+	// 中间层用自己的 writer 包装，HandlerFunc 拿到的不再是 Middleware 的 writer
+	wrap := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(struct{ http.ResponseWriter }{w}, r)
+	})
+	rec := httptest.NewRecorder()
+	m.Handler(wrap).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs", nil))
+
+	// 仍然能识别已经写出的响应头，不会追加 problem
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, 0, rec.Body.Len())
+	assert.Equal(t, int64(1), m.Metrics.Snapshot().Errors)
+}
+
+func TestMiddleware_AbortHandler(t *testing.T) {
+	h := (&Middleware{}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var m *Metrics
+	m.recordRequest()
+	m.recordError(1, 500, true)
+}
@@ -0,0 +1,47 @@
+package irrhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/khicago/irr"
+)
+
+// maxProblemSize limits the size of the problem body read by FromResponse.
+const maxProblemSize = 1 << 20
+
+// ParseProblem decodes a problem from r.
+func ParseProblem(r io.Reader) (*Problem, error) {
+	p := &Problem{}
+	if err := json.NewDecoder(r).Decode(p); err != nil {
+		if errors.Is(err, ErrInvalidProblem) {
+			return nil, err
+		}
+		return nil, irr.Wrap(ErrInvalidProblem, "%v", err)
+	}
+	return p, nil
+}
+
+// FromResponse converts a response into an error. It returns nil for statuses
+// below 400, the IRR error of the problem for problem+json responses, and an
+// IRR error with the status text otherwise. The body is read but not closed.
+func FromResponse(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == ContentType {
+		p, err := ParseProblem(io.LimitReader(resp.Body, maxProblemSize))
+		if err == nil {
+			if p.Status == 0 {
+				p.Status = resp.StatusCode
+			}
+			return p.Err()
+		}
+	}
+	err := irr.Error("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	err.SetAttr("status", resp.StatusCode)
+	return err
+}
@@ -0,0 +1,76 @@
+package irrhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/khicago/irr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProblem(t *testing.T) {
+	p, err := ParseProblem(strings.NewReader(`{"status":404,"code":92001}`))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(92001), p.Code)
+
+	_, err = ParseProblem(strings.NewReader(`{"status":`))
+	assert.True(t, errors.Is(err, ErrInvalidProblem), err)
+	_, err = ParseProblem(strings.NewReader(`{"code":"x"}`))
+	assert.True(t, errors.Is(err, ErrInvalidProblem), err)
+}
+
+func TestFromResponse_RoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := testCodeUserNotFound.Error("user %s not found", r.URL.Query().Get("id"))
+		err.SetTag("user", "u1")
+		(&Encoder{PublicTags: []string{"user"}, ExposeDetail: true}).Write(w, r, irr.Wrap(err, "get user"))
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/users?id=u1")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	got := FromResponse(resp)
+	assert.True(t, testCodeUserNotFound.Matches(got), got)
+	assert.Equal(t, []string{"u1"}, got.(irr.IRR).GetTag("user"))
+	assert.Equal(t, "get user, user u1 not found", got.(*irr.BasicIrr).Msg)
+	status, _ := got.(irr.IRR).GetAttr("status")
+	assert.Equal(t, int64(404), status.Int64())
+}
+
+func TestFromResponse(t *testing.T) {
+	assert.Nil(t, FromResponse(&http.Response{StatusCode: http.StatusOK}))
+
+	resp := &http.Response{
+		StatusCode: http.StatusBadGateway,
+		Header:     http.Header{"Content-Type": {"text/html"}},
+		Body:       http.NoBody,
+	}
+	err := FromResponse(resp)
+	assert.Equal(t, "502 Bad Gateway", err.(*irr.BasicIrr).Msg)
+
+	// 不完整的 problem 退化为状态码错误
+	resp = &http.Response{
+		StatusCode: http.StatusBadRequest,
+		Header:     http.Header{"Content-Type": {ContentType + "; charset=utf-8"}},
+		Body:       nopCloser{strings.NewReader(`{"title":`)},
+	}
+	assert.Equal(t, "400 Bad Request", FromResponse(resp).(*irr.BasicIrr).Msg)
+
+	// 缺少 status 时使用响应的状态码
+	resp.Body = nopCloser{strings.NewReader(`{"title":"bad input"}`)}
+	err = FromResponse(resp)
+	assert.Equal(t, "bad input", err.(*irr.BasicIrr).Msg)
+	status, _ := err.(irr.IRR).GetAttr("status")
+	assert.Equal(t, int64(400), status.Int64())
+}
+
+type nopCloser struct {
+	*strings.Reader
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,156 @@
+// Package irrhttp converts IRR error chains to and from RFC 9457
+// "application/problem+json" responses.
+//
+// The code of an error chain is mapped to an HTTP status by an Encoder, the
+// code and the public tags are carried as the "code" and "tags" extension
+// members, so that a Go client can turn the response back into an IRR error
+// with the original code.
+package irrhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/khicago/irr"
+)
+
+// Problem is the RFC 9457 problem details object.
+type Problem struct {
+	// Type is a URI reference that identifies the problem type, "about:blank" by default.
+	Type string
+	// Title is a short, human-readable summary of the problem type.
+	Title string
+	// Status is the HTTP status code.
+	Status int
+	// Detail is a human-readable explanation specific to this occurrence of the problem.
+	Detail string
+	// Instance is a URI reference that identifies the specific occurrence of the problem.
+	Instance string
+	// Code is the nearest code of the error chain, the "code" extension member.
+	Code int64
+	// Tags are the public tags of the error chain, the "tags" extension member.
+	Tags map[string]string
+	// Extensions are the other extension members.
+	Extensions map[string]any
+}
+
+const (
+	// ContentType is the media type of problem details.
+	ContentType = "application/problem+json"
+	// BlankType is the default problem type.
+	BlankType = "about:blank"
+)
+
+var (
+	_ json.Marshaler   = (*Problem)(nil)
+	_ json.Unmarshaler = (*Problem)(nil)
+
+	// ErrInvalidProblem is the cause of the errors returned when decoding malformed problem details.
+	ErrInvalidProblem = errors.New("invalid problem details")
+)
+
+// MarshalJSON
+// the implementation of json.Marshaler, extension members are flattened into
+// the top-level object and never override the standard members
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+7)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	members["type"] = p.Type
+	if p.Type == "" {
+		members["type"] = BlankType
+	}
+	setIfNotZero(members, "title", p.Title)
+	setIfNotZero(members, "status", p.Status)
+	setIfNotZero(members, "detail", p.Detail)
+	setIfNotZero(members, "instance", p.Instance)
+	setIfNotZero(members, "code", p.Code)
+	if len(p.Tags) > 0 {
+		members["tags"] = p.Tags
+	}
+	return json.Marshal(members)
+}
+
+// UnmarshalJSON
+// the implementation of json.Unmarshaler, unknown members are collected into Extensions
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	members := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &members); err != nil {
+		return irr.Wrap(ErrInvalidProblem, "decode members failed, %v", err)
+	}
+
+	*p = Problem{}
+	fields := map[string]any{
+		"type":     &p.Type,
+		"title":    &p.Title,
+		"status":   &p.Status,
+		"detail":   &p.Detail,
+		"instance": &p.Instance,
+		"code":     &p.Code,
+		"tags":     &p.Tags,
+	}
+	for key, raw := range members {
+		field, ok := fields[key]
+		if !ok {
+			var v any
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return irr.Wrap(ErrInvalidProblem, "decode extension %s failed, %v", key, err)
+			}
+			if p.Extensions == nil {
+				p.Extensions = make(map[string]any)
+			}
+			p.Extensions[key] = v
+			continue
+		}
+		if err := json.Unmarshal(raw, field); err != nil {
+			return irr.Wrap(ErrInvalidProblem, "decode member %s failed, %v", key, err)
+		}
+	}
+	if p.Type == "" {
+		p.Type = BlankType
+	}
+	return nil
+}
+
+// Err converts the problem back into an IRR error. The code and the tags are
+// restored, the message is the detail or the title when there is no detail,
+// and status, type and instance are kept as attrs.
+func (p *Problem) Err() irr.IRR {
+	msg := p.Detail
+	if msg == "" {
+		msg = p.Title
+	}
+	err := irr.Error(msg)
+	if p.Code != 0 {
+		err.SetCode(p.Code)
+	}
+
+	keys := make([]string, 0, len(p.Tags))
+	for k := range p.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		err.SetTag(k, p.Tags[k])
+	}
+
+	if p.Status != 0 {
+		err.SetAttr("status", p.Status)
+	}
+	if p.Type != "" && p.Type != BlankType {
+		err.SetAttr("type", p.Type)
+	}
+	if p.Instance != "" {
+		err.SetAttr("instance", p.Instance)
+	}
+	return err
+}
+
+func setIfNotZero[T comparable](members map[string]any, key string, val T) {
+	var zero T
+	if val != zero {
+		members[key] = val
+	}
+}
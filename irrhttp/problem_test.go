@@ -0,0 +1,80 @@
+package irrhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProblem_MarshalJSON(t *testing.T) {
+	p := &Problem{
+		Title:      "Not Found",
+		Status:     404,
+		Detail:     "user u1 not found",
+		Instance:   "/users/u1",
+		Code:       2001,
+		Tags:       map[string]string{"user": "u1"},
+		Extensions: map[string]any{"trace_id": "t1", "status": 200},
+	}
+	data, err := json.Marshal(p)
+	assert.NoError(t, err)
+	// 扩展字段不会覆盖标准字段
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"user u1 not found",
+		"instance":"/users/u1","code":2001,"tags":{"user":"u1"},"trace_id":"t1"}`, string(data))
+
+	data, err = json.Marshal(&Problem{Status: 500})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type":"about:blank","status":500}`, string(data))
+}
+
+func TestProblem_UnmarshalJSON(t *testing.T) {
+	p := &Problem{}
+	err := json.Unmarshal([]byte(`{"title":"Conflict","status":409,"code":2002,"tags":{"k":"v"},"trace_id":"t1","retry":{"after":3}}`), p)
+	assert.NoError(t, err)
+	assert.Equal(t, &Problem{
+		Type:       BlankType,
+		Title:      "Conflict",
+		Status:     409,
+		Code:       2002,
+		Tags:       map[string]string{"k": "v"},
+		Extensions: map[string]any{"trace_id": "t1", "retry": map[string]any{"after": float64(3)}},
+	}, p)
+
+	err = json.Unmarshal([]byte(`{"status":"409"}`), p)
+	assert.True(t, errors.Is(err, ErrInvalidProblem), err)
+	err = json.Unmarshal([]byte(`[1]`), p)
+	assert.True(t, errors.Is(err, ErrInvalidProblem), err)
+}
+
+func TestProblem_Err(t *testing.T) {
+	p := &Problem{
+		Type:     "https://docs.example.com/errors/USER_NOT_FOUND",
+		Title:    "user not found",
+		Status:   404,
+		Detail:   "user u1 not found, 100%",
+		Instance: "/users/u1",
+		Code:     2001,
+		Tags:     map[string]string{"user": "u1", "a": "b"},
+	}
+	err := p.Err()
+	assert.Equal(t, int64(2001), err.NearestCode())
+	assert.Equal(t, "code(2001), user u1 not found, 100%[a:b] [user:u1] [status:404] "+
+		"[type:https://docs.example.com/errors/USER_NOT_FOUND] [instance:/users/u1] ", err.Error())
+
+	status, ok := err.GetAttr("status")
+	assert.True(t, ok)
+	assert.Equal(t, int64(404), status.Int64())
+	typ, _ := err.GetAttr("type")
+	assert.Equal(t, p.Type, typ.String())
+	instance, _ := err.GetAttr("instance")
+	assert.Equal(t, "/users/u1", instance.String())
+
+	err = (&Problem{Type: BlankType, Title: "Bad Request"}).Err()
+	assert.Equal(t, "Bad Request", err.Error())
+	assert.Equal(t, []string(nil), err.GetTag("status"))
+	assert.False(t, err.HasAnyCode())
+	_, ok = err.GetAttr("type")
+	assert.False(t, ok)
+}
@@ -0,0 +1,140 @@
+// Package irrprom exposes the error metrics of irr in the Prometheus text
+// exposition format, without depending on the Prometheus client library.
+//
+//	http.Handle("/metrics/errors", &irrprom.Handler{})
+//
+// The counters of irr.ErrorMetrics are rendered as counters, the per-code
+// counters are labeled with the code and its registered name when known.
+package irrprom
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+)
+
+// Handler renders error metrics for Prometheus, the zero value renders
+// irr.GetMetrics() with the "irr" namespace.
+type Handler struct {
+	// Metrics returns the metrics to render, irr.GetMetrics when it's nil. Use the
+	// Snapshot method of a scoped irr.ErrorMetrics to expose it instead.
+	Metrics func() *irr.ErrorMetrics
+	// Namespace is the prefix of the metric names, DefaultNamespace when it's empty.
+	Namespace string
+	// Registry resolves the names of codes, irc.DefaultRegistry when it's nil.
+	Registry *irc.Registry
+}
+
+const (
+	// ContentType is the content type of the text exposition format.
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	DefaultNamespace = "irr"
+)
+
+// ServeHTTP
+// the implementation of http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_ = h.Write(w)
+}
+
+// Write writes the metrics in the text exposition format to w.
+func (h *Handler) Write(w io.Writer) error {
+	m := h.metrics()
+	ns := h.Namespace
+	if ns == "" {
+		ns = DefaultNamespace
+	}
+
+	bw := bufio.NewWriter(w)
+	writeCounter(bw, ns+"_errors_created_total", "Total number of errors created, wrapping layers included.", m.ErrorCreated)
+	writeCounter(bw, ns+"_errors_wrapped_total", "Total number of errors wrapped.", m.ErrorWrapped)
+	writeCounter(bw, ns+"_errors_traced_total", "Total number of errors created with stack traces.", m.ErrorWithTrace)
+	writeCounter(bw, ns+"_errors_with_code_total", "Total number of codes set to errors.", m.ErrorWithCode)
+	writeCounter(bw, ns+"_error_traversals_total", "Total number of error chain traversals.", m.TraverseOps)
+
+	name := ns + "_errors_by_code_total"
+	writeHeader(bw, name, "Total number of errors by code.", "counter")
+	codes := make([]int64, 0, len(m.CodeStats))
+	for code := range m.CodeStats {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	for _, code := range codes {
+		bw.WriteString(name)
+		bw.WriteString(`{code="`)
+		bw.WriteString(strconv.FormatInt(code, 10))
+		bw.WriteString(`",name="`)
+		bw.WriteString(escapeLabel(h.codeName(code)))
+		bw.WriteString(`"} `)
+		bw.WriteString(strconv.FormatInt(m.CodeStats[code], 10))
+		bw.WriteByte('\n')
+	}
+
+	name = ns + "_last_error_timestamp_seconds"
+	writeHeader(bw, name, "Unix time of the last error created, 0 when there is none.", "gauge")
+	last := 0.0
+	if !m.LastErrorTime.IsZero() {
+		last = float64(m.LastErrorTime.UnixNano()) / 1e9
+	}
+	bw.WriteString(name)
+	bw.WriteByte(' ')
+	bw.WriteString(strconv.FormatFloat(last, 'f', -1, 64))
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
+
+func (h *Handler) metrics() *irr.ErrorMetrics {
+	if h.Metrics != nil {
+		if m := h.Metrics(); m != nil {
+			return m
+		}
+	}
+	return irr.GetMetrics()
+}
+
+// codeName returns the registered name of the code, or "" when it's unknown.
+func (h *Handler) codeName(code int64) string {
+	registry := h.Registry
+	if registry == nil {
+		registry = irc.DefaultRegistry
+	}
+	if meta, ok := registry.Lookup(irc.Code(code)); ok {
+		return meta.Name
+	}
+	return ""
+}
+
+func writeHeader(bw *bufio.Writer, name, help, typ string) {
+	bw.WriteString("# HELP ")
+	bw.WriteString(name)
+	bw.WriteByte(' ')
+	bw.WriteString(help)
+	bw.WriteString("\n# TYPE ")
+	bw.WriteString(name)
+	bw.WriteByte(' ')
+	bw.WriteString(typ)
+	bw.WriteByte('\n')
+}
+
+func writeCounter(bw *bufio.Writer, name, help string, val int64) {
+	writeHeader(bw, name, help, "counter")
+	bw.WriteString(name)
+	bw.WriteByte(' ')
+	bw.WriteString(strconv.FormatInt(val, 10))
+	bw.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes the label value as the text exposition format requires.
+func escapeLabel(val string) string {
+	return labelEscaper.Replace(val)
+}
@@ -0,0 +1,87 @@
+package irrprom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_Write(t *testing.T) {
+	registry := irc.NewRegistry()
+	registry.MustRegister(irc.Meta{Code: 97001, Name: "TEST_USER_NOT_FOUND"})
+	registry.MustRegister(irc.Meta{Code: 97002, Name: `TEST_"QUOTED"`})
+
+	h := &Handler{
+		Namespace: "app",
+		Registry:  registry,
+		Metrics: func() *irr.ErrorMetrics {
+			return &irr.ErrorMetrics{
+				ErrorCreated:   10,
+				ErrorWrapped:   4,
+				ErrorWithTrace: 3,
+				ErrorWithCode:  6,
+				TraverseOps:    2,
+				LastErrorTime:  time.Unix(1700000000, 500000000),
+				CodeStats:      map[int64]int64{97002: 1, 97001: 3, -1: 2},
+			}
+		},
+	}
+	sb := &strings.Builder{}
+	assert.NoError(t, h.Write(sb))
+	assert.Equal(t, `# HELP app_errors_created_total Total number of errors created, wrapping layers included.
+# TYPE app_errors_created_total counter
+app_errors_created_total 10
+# HELP app_errors_wrapped_total Total number of errors wrapped.
+# TYPE app_errors_wrapped_total counter
+app_errors_wrapped_total 4
+# HELP app_errors_traced_total Total number of errors created with stack traces.
+# TYPE app_errors_traced_total counter
+app_errors_traced_total 3
+# HELP app_errors_with_code_total Total number of codes set to errors.
+# TYPE app_errors_with_code_total counter
+app_errors_with_code_total 6
+# HELP app_error_traversals_total Total number of error chain traversals.
+# TYPE app_error_traversals_total counter
+app_error_traversals_total 2
+# HELP app_errors_by_code_total Total number of errors by code.
+# TYPE app_errors_by_code_total counter
+app_errors_by_code_total{code="-1",name=""} 2
+app_errors_by_code_total{code="97001",name="TEST_USER_NOT_FOUND"} 3
+app_errors_by_code_total{code="97002",name="TEST_\"QUOTED\""} 1
+# HELP app_last_error_timestamp_seconds Unix time of the last error created, 0 when there is none.
+# TYPE app_last_error_timestamp_seconds gauge
+app_last_error_timestamp_seconds 1700000000.5
+`, sb.String())
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	irr.ResetMetrics()
+	defer irr.ResetMetrics()
+	_ = irr.ErrorC(97003, "boom")
+
+	rec := httptest.NewRecorder()
+	(&Handler{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	body := rec.Body.String()
+	assert.Contains(t, body, "\nirr_errors_created_total 1\n")
+	assert.Contains(t, body, "\nirr_errors_by_code_total{code=\"97003\",name=\"\"} 1\n")
+	assert.NotContains(t, body, "irr_last_error_timestamp_seconds 0\n")
+
+	// 没有错误时时间戳为 0
+	irr.ResetMetrics()
+	rec = httptest.NewRecorder()
+	(&Handler{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "\nirr_last_error_timestamp_seconds 0\n")
+}
+
+func TestEscapeLabel(t *testing.T) {
+	assert.Equal(t, `a\\b\"c\nd`, escapeLabel("a\\b\"c\nd"))
+}
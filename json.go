@@ -0,0 +1,250 @@
+package irr
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type (
+	// jsonNode 是错误链中每一层的 JSON 表示
+	// BasicIrr 输出 code/msg/tags/attrs/trace，聚合错误输出 errors，
+	// 其他错误只保留消息，并标记 foreign
+	jsonNode struct {
+		Code    int64       `json:"code,omitempty"`
+		CodeSet bool        `json:"code_set,omitempty"`
+		Msg     string      `json:"msg,omitempty"`
+		RawMsg  string      `json:"raw_msg,omitempty"`
+		Public  string      `json:"public,omitempty"`
+		Tags    []Tag       `json:"tags,omitempty"`
+		Attrs   []jsonAttr  `json:"attrs,omitempty"`
+		Trace   *traceInfo  `json:"trace,omitempty"`
+		Foreign bool        `json:"foreign,omitempty"`
+		Errors  []*jsonNode `json:"errors,omitempty"`
+		Inner   *jsonNode   `json:"inner,omitempty"`
+
+		// 重试分类，以及以纳秒为单位的重试等待时间
+		Retry      string        `json:"retry,omitempty"`
+		RetryAfter time.Duration `json:"retry_after,omitempty"`
+	}
+
+	// Decoded 是解码得到的错误链，编码的错误为 nil 时 Err 为 nil
+	Decoded struct {
+		Err error
+	}
+
+	// foreignError 是解码后的非 IRR 错误，只保留消息，并保持与内层错误的链接
+	foreignError struct {
+		msg   string
+		inner error
+	}
+
+	// CodecOption 配置 MarshalErrorJSON 与 Encode
+	CodecOption func(c *codecConfig)
+
+	codecConfig struct {
+		reveal bool
+	}
+)
+
+var (
+	_ json.Marshaler   = (*BasicIrr)(nil)
+	_ json.Unmarshaler = (*BasicIrr)(nil)
+	_ json.Marshaler   = (*MultiIrr)(nil)
+	_ json.Unmarshaler = (*MultiIrr)(nil)
+
+	// ErrInvalidJSONNode 表示 JSON 中的错误节点与目标类型不匹配
+	ErrInvalidJSONNode = errors.New("invalid json error node")
+)
+
+// WithSecrets 使编码结果保留 Secret 参数与值、敏感 key 的原始值，解码后的 RevealMsg 等依然可用
+// 默认的编码结果与 Error() 一样脱敏，只应在受信任的服务之间传递时使用
+func WithSecrets() CodecOption {
+	return func(c *codecConfig) {
+		c.reveal = true
+	}
+}
+
+// MarshalJSON
+// the implementation of json.Marshaler, the whole chain is serialized,
+// including msg, code, tags and trace of each layer, secrets are redacted
+func (ir *BasicIrr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newJSONNode(ir, newCodecConfig(nil)))
+}
+
+// UnmarshalJSON
+// the implementation of json.Unmarshaler, it reconstructs the chain
+// serialized by MarshalJSON
+func (ir *BasicIrr) UnmarshalJSON(data []byte) error {
+	node := &jsonNode{}
+	if err := json.Unmarshal(data, node); err != nil {
+		return err
+	}
+	if node.Foreign || node.Errors != nil {
+		return Wrap(ErrInvalidJSONNode, "cannot decode to BasicIrr")
+	}
+	node.fillBasicIrr(ir)
+	return nil
+}
+
+// MarshalJSON 实现 json.Marshaler，输出所有分支
+func (m *MultiIrr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newJSONNode(m, newCodecConfig(nil)))
+}
+
+// UnmarshalJSON 实现 json.Unmarshaler，解码的聚合错误使用 PickFirstCode 策略
+func (m *MultiIrr) UnmarshalJSON(data []byte) error {
+	node := &jsonNode{}
+	if err := json.Unmarshal(data, node); err != nil {
+		return err
+	}
+	if node.Errors == nil {
+		return Wrap(ErrInvalidJSONNode, "cannot decode to MultiIrr")
+	}
+	m.errs = node.branches()
+	return nil
+}
+
+// MarshalErrorJSON 将任意错误序列化为 JSON，非 IRR 错误被序列化为仅包含消息的节点
+// 与 Error() 一样，Secret 与敏感 key 的值默认只保留掩码，见 WithSecrets
+func MarshalErrorJSON(err error, opts ...CodecOption) ([]byte, error) {
+	if err == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(newJSONNode(err, newCodecConfig(opts)))
+}
+
+// UnmarshalErrorJSON 解码 MarshalErrorJSON 或 MarshalJSON 的输出
+// 解码得到的错误链在 Decoded.Err 中，返回的 error 只表示解码过程中的失败
+func UnmarshalErrorJSON(data []byte) (Decoded, error) {
+	var node *jsonNode
+	if err := json.Unmarshal(data, &node); err != nil {
+		return Decoded{}, err
+	}
+	if node == nil {
+		return Decoded{}, nil
+	}
+	return Decoded{Err: node.toError()}, nil
+}
+
+func newCodecConfig(opts []CodecOption) codecConfig {
+	var c codecConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+func newJSONNode(err error, c codecConfig) *jsonNode {
+	switch e := err.(type) {
+	case *BasicIrr:
+		return newBasicJSONNode(e, c)
+	case *ContextualIrr:
+		// 上下文无法跨进程传递，只保留错误本身
+		return newBasicJSONNode(e.BasicIrr, c)
+	case interface{ Unwrap() []error }:
+		branches := e.Unwrap()
+		node := &jsonNode{Errors: make([]*jsonNode, 0, len(branches))}
+		for _, branch := range branches {
+			node.Errors = append(node.Errors, newJSONNode(branch, c))
+		}
+		return node
+	default:
+		node := &jsonNode{Msg: err.Error(), Foreign: true}
+		if inner := errors.Unwrap(err); inner != nil {
+			node.Inner = newJSONNode(inner, c)
+		}
+		return node
+	}
+}
+
+func newBasicJSONNode(ir *BasicIrr, c codecConfig) *jsonNode {
+	node := &jsonNode{
+		Code:    ir.Code,
+		CodeSet: ir.codeSet,
+		Msg:     ir.Msg,
+		Public:  ir.publicMsg,
+		Trace:   ir.Trace,
+	}
+	if ir.retryClass != ClassUnknown {
+		node.Retry = ir.retryClass.String()
+	}
+	node.RetryAfter = ir.retryAfter
+	node.Tags = ir.AllTags()
+	attrs := ir.attrs.Load()
+	if c.reveal {
+		node.RawMsg = ir.rawMsg
+	} else {
+		node.Tags = redactTags(node.Tags)
+	}
+	if attrs != nil && len(*attrs) > 0 {
+		list := *attrs
+		if !c.reveal {
+			list = redactAttrs(list)
+		}
+		node.Attrs = newJSONAttrs(list, c.reveal)
+	}
+	if ir.inner != nil {
+		node.Inner = newJSONNode(ir.inner, c)
+	}
+	return node
+}
+
+func (n *jsonNode) toError() error {
+	switch {
+	case n.Errors != nil:
+		return &MultiIrr{errs: n.branches()}
+	case n.Foreign:
+		fe := &foreignError{msg: n.Msg}
+		if n.Inner != nil {
+			fe.inner = n.Inner.toError()
+		}
+		return fe
+	default:
+		ir := &BasicIrr{}
+		n.fillBasicIrr(ir)
+		return ir
+	}
+}
+
+func (n *jsonNode) fillBasicIrr(ir *BasicIrr) {
+	ir.Code = n.Code
+	ir.codeSet = n.CodeSet || n.Code != 0
+	ir.Msg = n.Msg
+	ir.rawMsg = n.RawMsg
+	ir.publicMsg = n.Public
+	ir.retryClass = parseRetryClass(n.Retry)
+	ir.retryAfter = n.RetryAfter
+	ir.Trace = n.Trace
+	for _, tag := range n.Tags {
+		ir.SetTag(tag.Key, tag.Value)
+	}
+	for _, attr := range n.Attrs {
+		ir.setAttr(attr.toAttr())
+	}
+	if n.Inner != nil {
+		ir.inner = n.Inner.toError()
+	}
+}
+
+func (n *jsonNode) branches() []error {
+	errs := make([]error, 0, len(n.Errors))
+	for _, branch := range n.Errors {
+		if branch != nil {
+			errs = append(errs, branch.toError())
+		}
+	}
+	return errs
+}
+
+// Error
+// the implementation of error
+func (e *foreignError) Error() string {
+	return e.msg
+}
+
+// Unwrap
+// the implementation of IUnwrap
+func (e *foreignError) Unwrap() error {
+	return e.inner
+}
@@ -0,0 +1,130 @@
+package irr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasicIrrJSON(t *testing.T) {
+	root := errors.New("connection refused")
+	inner := Track(fmt.Errorf("dial: %w", root), "query user").SetCode(5001)
+	inner.SetTag("db", "users")
+	inner.SetTag("db", "replica")
+	outer := Wrap(inner, "load profile")
+	outer.SetTag("uid", "42")
+
+	data, err := json.Marshal(outer)
+	assert.NoError(t, err)
+
+	decoded := &BasicIrr{}
+	assert.NoError(t, json.Unmarshal(data, decoded))
+
+	assert.Equal(t, outer.Error(), decoded.Error())
+	assert.Equal(t, outer.ToString(true, "\n"), decoded.ToString(true, "\n"))
+	assert.Equal(t, int64(5001), decoded.NearestCode())
+	assert.False(t, decoded.HasCurrentCode())
+	assert.Equal(t, []string{"42"}, decoded.GetTag("uid"))
+
+	decodedInner, ok := decoded.Unwrap().(*BasicIrr)
+	assert.True(t, ok)
+	assert.True(t, decodedInner.HasCurrentCode())
+	assert.Equal(t, []string{"users", "replica"}, decodedInner.GetTag("db"))
+	assert.Equal(t, inner.GetTraceInfo().String(), decodedInner.GetTraceInfo().String())
+
+	// 外部错误只保留消息，但链接关系得以保留
+	foreign := decodedInner.Unwrap()
+	assert.Equal(t, "dial: connection refused", foreign.Error())
+	assert.Equal(t, "connection refused", errors.Unwrap(foreign).Error())
+	assert.True(t, errors.Is(decoded, ErrorC(5001, "")))
+
+	// 再次编码结果一致
+	again, err := json.Marshal(decoded)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(data), string(again))
+}
+
+func TestBasicIrrJSONFormat(t *testing.T) {
+	err := Wrap(errors.New("std"), "outer").SetCode(400)
+	err.SetTag("b", "2")
+	err.SetTag("a", "1")
+
+	data, e := json.Marshal(err)
+	assert.NoError(t, e)
+	assert.JSONEq(t, `{
+		"code": 400,
+		"code_set": true,
+		"msg": "outer",
+		"tags": [{"key": "b", "value": "2"}, {"key": "a", "value": "1"}],
+		"inner": {"msg": "std", "foreign": true}
+	}`, string(data))
+
+	// 嵌入在其他结构中同样生效
+	data, e = json.Marshal(struct {
+		Err error `json:"err"`
+	}{Err: err})
+	assert.NoError(t, e)
+	assert.Contains(t, string(data), `"code":400`)
+}
+
+func TestMultiIrrJSON(t *testing.T) {
+	joined := JoinWith(PickMaxCode, ErrorC(400, "a"), Wrap(ErrorC(500, "b"), "wrap b"))
+	err := Wrap(joined, "batch")
+
+	data, e := json.Marshal(err)
+	assert.NoError(t, e)
+
+	decoded := &BasicIrr{}
+	assert.NoError(t, json.Unmarshal(data, decoded))
+	assert.Equal(t, err.Error(), decoded.Error())
+	assert.Equal(t, int64(400), decoded.NearestCode()) // 策略不会被序列化，解码后使用默认策略
+	assert.True(t, errors.Is(decoded, ErrorC(500, "")))
+
+	data, e = json.Marshal(joined)
+	assert.NoError(t, e)
+	m := &MultiIrr{}
+	assert.NoError(t, json.Unmarshal(data, m))
+	assert.Equal(t, 2, m.Len())
+	assert.Equal(t, joined.Error(), m.Error())
+
+	assert.ErrorIs(t, json.Unmarshal(data, &BasicIrr{}), ErrInvalidJSONNode)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"msg":"x"}`), &MultiIrr{}), ErrInvalidJSONNode)
+	assert.Error(t, json.Unmarshal([]byte(`{"msg":`), &BasicIrr{}))
+	assert.Error(t, json.Unmarshal([]byte(`[]`), &MultiIrr{}))
+}
+
+func TestMarshalErrorJSON(t *testing.T) {
+	data, err := MarshalErrorJSON(nil)
+	assert.NoError(t, err)
+	decoded, err := UnmarshalErrorJSON(data)
+	assert.NoError(t, err)
+	assert.Nil(t, decoded.Err)
+
+	std := fmt.Errorf("rpc: %w", ErrorC(404, "not found"))
+	data, err = MarshalErrorJSON(std)
+	assert.NoError(t, err)
+	decoded, err = UnmarshalErrorJSON(data)
+	assert.NoError(t, err)
+	assert.Equal(t, std.Error(), decoded.Err.Error())
+	assert.True(t, errors.Is(decoded.Err, ErrorC(404, "")))
+
+	ctxErr := ErrorWithContext(context.Background(), "with ctx")
+	data, err = MarshalErrorJSON(ctxErr)
+	assert.NoError(t, err)
+	decoded, err = UnmarshalErrorJSON(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "with ctx", decoded.Err.Error())
+
+	data, err = MarshalErrorJSON(Join(Error("a"), errors.New("b")))
+	assert.NoError(t, err)
+	decoded, err = UnmarshalErrorJSON(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "{a; b}", decoded.Err.Error())
+
+	_, err = UnmarshalErrorJSON([]byte("{"))
+	assert.Error(t, err)
+}
@@ -6,7 +6,9 @@ import (
 	"time"
 )
 
-// ErrorMetrics 错误统计信息
+// ErrorMetrics 错误统计信息，也是默认的 MetricsSink 实现
+// 作为 MetricsSink 使用时，计数器通过原子操作更新，错误码计数保存在 sync.Map 中，
+// 记录过程中没有锁；LastErrorTime 与 CodeStats 只在 Snapshot 返回的副本中有效
 type ErrorMetrics struct {
 	// 错误创建统计
 	ErrorCreated   int64 `json:"error_created"`
@@ -21,75 +23,226 @@ type ErrorMetrics struct {
 	LastErrorTime time.Time `json:"last_error_time"`
 
 	// 错误码统计
-	CodeStats      map[int64]int64 `json:"code_stats"`
-	codeStatsMutex sync.RWMutex    `json:"-"`
+	CodeStats map[int64]int64 `json:"code_stats"`
+
+	// lastErrorNano 最后一次创建错误的 UnixNano，0 表示没有
+	lastErrorNano atomic.Int64
+	// codeCounters 保存错误码到 *atomic.Int64 的映射
+	codeCounters sync.Map
+}
+
+// MetricsSink 接收错误事件，用于统计或转发到监控系统，实现必须是并发安全的
+// 默认注册的是全局的 ErrorMetrics（见 GetMetrics），可以通过 SetMetricsSinks 替换
+type MetricsSink interface {
+	OnCreated()        // 创建了一个错误，包括包装产生的错误
+	OnWrapped()        // 包装了一个错误
+	OnTraced()         // 创建了一个带堆栈跟踪的错误
+	OnCode(code int64) // 为错误设置了错误码
+	OnTraverse()       // 遍历了一次错误链
+}
+
+// NoopMetricsSink 不做任何事的 MetricsSink
+// 注册时会被忽略，SetMetricsSinks(NoopMetricsSink{}) 即可关闭全部统计且没有额外开销
+type NoopMetricsSink struct{}
+
+var (
+	globalMetrics = NewErrorMetrics()
+
+	// metricsSinks 保存 []MetricsSink，只整体替换，读取时无需加锁
+	// 在变量声明中初始化，包级变量的初始化中创建错误也能安全地记录
+	metricsSinks = newMetricsSinks(globalMetrics)
+	// metricsSinksMu 串行化对 metricsSinks 的修改，避免并发注册时丢失 sink
+	metricsSinksMu sync.Mutex
+)
+
+// NewErrorMetrics 创建一个空的内存统计，可以通过 AddMetricsSink 注册，
+// 用于按服务或按测试单独统计
+func NewErrorMetrics() *ErrorMetrics {
+	return &ErrorMetrics{}
+}
+
+// SetMetricsSinks 替换所有已注册的 MetricsSink，nil 与 NoopMetricsSink 会被忽略
+// 不传参数时关闭全部统计，此时 GetMetrics 不再变化
+func SetMetricsSinks(sinks ...MetricsSink) {
+	metricsSinksMu.Lock()
+	defer metricsSinksMu.Unlock()
+	storeMetricsSinks(sinks)
+}
+
+// storeMetricsSinks 过滤并保存 sinks，调用方需要持有 metricsSinksMu
+func storeMetricsSinks(sinks []MetricsSink) {
+	list := make([]MetricsSink, 0, len(sinks))
+	for _, sink := range sinks {
+		if sink == nil {
+			continue
+		}
+		if _, ok := sink.(NoopMetricsSink); ok {
+			continue
+		}
+		list = append(list, sink)
+	}
+	metricsSinks.Store(&list)
+}
+
+func newMetricsSinks(sinks ...MetricsSink) *atomic.Pointer[[]MetricsSink] {
+	p := &atomic.Pointer[[]MetricsSink]{}
+	p.Store(&sinks)
+	return p
+}
+
+// AddMetricsSink 在已注册的 MetricsSink 之后追加 sink
+func AddMetricsSink(sink MetricsSink) {
+	metricsSinksMu.Lock()
+	defer metricsSinksMu.Unlock()
+	storeMetricsSinks(append(MetricsSinks(), sink))
 }
 
-var globalMetrics = &ErrorMetrics{
-	CodeStats: make(map[int64]int64),
+// MetricsSinks 返回已注册的 MetricsSink 的副本
+func MetricsSinks() []MetricsSink {
+	sinks := *metricsSinks.Load()
+	return append(make([]MetricsSink, 0, len(sinks)), sinks...)
 }
 
 // GetMetrics 获取全局错误统计信息
 func GetMetrics() *ErrorMetrics {
-	globalMetrics.codeStatsMutex.RLock()
-	defer globalMetrics.codeStatsMutex.RUnlock()
+	return globalMetrics.Snapshot()
+}
 
-	// 返回副本以避免并发问题
-	result := &ErrorMetrics{
-		ErrorCreated:   atomic.LoadInt64(&globalMetrics.ErrorCreated),
-		ErrorWithCode:  atomic.LoadInt64(&globalMetrics.ErrorWithCode),
-		ErrorWithTrace: atomic.LoadInt64(&globalMetrics.ErrorWithTrace),
-		ErrorWrapped:   atomic.LoadInt64(&globalMetrics.ErrorWrapped),
-		TraverseOps:    atomic.LoadInt64(&globalMetrics.TraverseOps),
-		LastErrorTime:  globalMetrics.LastErrorTime,
-		CodeStats:      make(map[int64]int64, len(globalMetrics.CodeStats)),
+// ResetMetrics 重置全局统计信息，开启了全局滑动窗口统计时一并重置
+func ResetMetrics() {
+	globalMetrics.Reset()
+	if s := globalWindowStats.Load(); s != nil {
+		s.Reset()
 	}
+}
 
-	for code, count := range globalMetrics.CodeStats {
-		result.CodeStats[code] = count
+// Snapshot 返回统计信息的副本
+// 各计数器分别原子读取，并发记录时副本不保证是同一时刻的
+func (m *ErrorMetrics) Snapshot() *ErrorMetrics {
+	result := &ErrorMetrics{
+		ErrorCreated:   atomic.LoadInt64(&m.ErrorCreated),
+		ErrorWithCode:  atomic.LoadInt64(&m.ErrorWithCode),
+		ErrorWithTrace: atomic.LoadInt64(&m.ErrorWithTrace),
+		ErrorWrapped:   atomic.LoadInt64(&m.ErrorWrapped),
+		TraverseOps:    atomic.LoadInt64(&m.TraverseOps),
+		CodeStats:      make(map[int64]int64),
+	}
+	if nano := m.lastErrorNano.Load(); nano != 0 {
+		result.LastErrorTime = time.Unix(0, nano)
 	}
+	m.codeCounters.Range(func(code, counter any) bool {
+		if count := counter.(*atomic.Int64).Load(); count != 0 {
+			result.CodeStats[code.(int64)] = count
+		}
+		return true
+	})
 
 	return result
 }
 
-// ResetMetrics 重置统计信息
-func ResetMetrics() {
-	atomic.StoreInt64(&globalMetrics.ErrorCreated, 0)
-	atomic.StoreInt64(&globalMetrics.ErrorWithCode, 0)
-	atomic.StoreInt64(&globalMetrics.ErrorWithTrace, 0)
-	atomic.StoreInt64(&globalMetrics.ErrorWrapped, 0)
-	atomic.StoreInt64(&globalMetrics.TraverseOps, 0)
+// Reset 重置统计信息
+// 与记录并发执行时，重置期间记录的少量计数可能丢失
+func (m *ErrorMetrics) Reset() {
+	atomic.StoreInt64(&m.ErrorCreated, 0)
+	atomic.StoreInt64(&m.ErrorWithCode, 0)
+	atomic.StoreInt64(&m.ErrorWithTrace, 0)
+	atomic.StoreInt64(&m.ErrorWrapped, 0)
+	atomic.StoreInt64(&m.TraverseOps, 0)
+	m.lastErrorNano.Store(0)
+
+	m.codeCounters.Range(func(code, _ any) bool {
+		m.codeCounters.Delete(code)
+		return true
+	})
+}
+
+// OnCreated
+// the implementation of MetricsSink
+func (m *ErrorMetrics) OnCreated() {
+	atomic.AddInt64(&m.ErrorCreated, 1)
+	m.lastErrorNano.Store(time.Now().UnixNano())
+}
+
+// OnCode
+// the implementation of MetricsSink
+func (m *ErrorMetrics) OnCode(code int64) {
+	atomic.AddInt64(&m.ErrorWithCode, 1)
+
+	// 已有的错误码只需一次无锁读取
+	counter, ok := m.codeCounters.Load(code)
+	if !ok {
+		counter, _ = m.codeCounters.LoadOrStore(code, new(atomic.Int64))
+	}
+	counter.(*atomic.Int64).Add(1)
+}
+
+// OnTraced
+// the implementation of MetricsSink
+func (m *ErrorMetrics) OnTraced() {
+	atomic.AddInt64(&m.ErrorWithTrace, 1)
+}
+
+// OnWrapped
+// the implementation of MetricsSink
+func (m *ErrorMetrics) OnWrapped() {
+	atomic.AddInt64(&m.ErrorWrapped, 1)
+}
 
-	globalMetrics.codeStatsMutex.Lock()
-	globalMetrics.CodeStats = make(map[int64]int64)
-	globalMetrics.LastErrorTime = time.Time{}
-	globalMetrics.codeStatsMutex.Unlock()
+// OnTraverse
+// the implementation of MetricsSink
+func (m *ErrorMetrics) OnTraverse() {
+	atomic.AddInt64(&m.TraverseOps, 1)
 }
 
-// 内部统计函数
+// OnCreated
+// the implementation of MetricsSink
+func (NoopMetricsSink) OnCreated() {}
+
+// OnWrapped
+// the implementation of MetricsSink
+func (NoopMetricsSink) OnWrapped() {}
+
+// OnTraced
+// the implementation of MetricsSink
+func (NoopMetricsSink) OnTraced() {}
+
+// OnCode
+// the implementation of MetricsSink
+func (NoopMetricsSink) OnCode(int64) {}
+
+// OnTraverse
+// the implementation of MetricsSink
+func (NoopMetricsSink) OnTraverse() {}
+
+// 内部统计函数，分发到所有已注册的 MetricsSink
 func recordErrorCreated() {
-	atomic.AddInt64(&globalMetrics.ErrorCreated, 1)
-	globalMetrics.LastErrorTime = time.Now()
+	for _, sink := range *metricsSinks.Load() {
+		sink.OnCreated()
+	}
 }
 
 func recordErrorWithCode(code int64) {
-	atomic.AddInt64(&globalMetrics.ErrorWithCode, 1)
-
-	globalMetrics.codeStatsMutex.Lock()
-	globalMetrics.CodeStats[code]++
-	globalMetrics.codeStatsMutex.Unlock()
+	for _, sink := range *metricsSinks.Load() {
+		sink.OnCode(code)
+	}
 }
 
 func recordErrorWithTrace() {
-	atomic.AddInt64(&globalMetrics.ErrorWithTrace, 1)
+	for _, sink := range *metricsSinks.Load() {
+		sink.OnTraced()
+	}
 }
 
 func recordErrorWrapped() {
-	atomic.AddInt64(&globalMetrics.ErrorWrapped, 1)
+	for _, sink := range *metricsSinks.Load() {
+		sink.OnWrapped()
+	}
 }
 
 func recordTraverseOp() {
-	atomic.AddInt64(&globalMetrics.TraverseOps, 1)
+	for _, sink := range *metricsSinks.Load() {
+		sink.OnTraverse()
+	}
 }
 
 // ErrorStatsLogger 错误统计日志接口
@@ -1,6 +1,8 @@
 package irr
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -90,3 +92,152 @@ func TestMetricsConcurrent(t *testing.T) {
 		assert.Equal(t, int64(100), metrics.CodeStats[int64(i)], "错误码 %d 的统计不正确", i)
 	}
 }
+
+// countingSink 记录收到的事件
+type countingSink struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (s *countingSink) add(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func (s *countingSink) OnCreated()        { s.add("created") }
+func (s *countingSink) OnWrapped()        { s.add("wrapped") }
+func (s *countingSink) OnTraced()         { s.add("traced") }
+func (s *countingSink) OnCode(code int64) { s.add(fmt.Sprintf("code(%d)", code)) }
+func (s *countingSink) OnTraverse()       { s.add("traverse") }
+
+func TestMetricsSinks(t *testing.T) {
+	prev := MetricsSinks()
+	defer SetMetricsSinks(prev...)
+
+	// 默认注册的是全局统计
+	assert.Equal(t, []MetricsSink{globalMetrics}, prev)
+
+	sink := &countingSink{}
+	scoped := NewErrorMetrics()
+	SetMetricsSinks(sink, nil, NoopMetricsSink{})
+	AddMetricsSink(scoped)
+	assert.Equal(t, []MetricsSink{sink, scoped}, MetricsSinks())
+
+	ResetMetrics()
+	err := TrackFull(ErrorC(3001, "inner"), "outer")
+	_ = err.TraverseToRoot(func(e error) error { return nil })
+
+	assert.Equal(t, []string{"created", "code(3001)", "created", "wrapped", "traced", "traverse"}, sink.events)
+	metrics := scoped.Snapshot()
+	assert.Equal(t, int64(2), metrics.ErrorCreated)
+	assert.Equal(t, int64(1), metrics.ErrorWrapped)
+	assert.Equal(t, int64(1), metrics.ErrorWithTrace)
+	assert.Equal(t, int64(1), metrics.TraverseOps)
+	assert.Equal(t, map[int64]int64{3001: 1}, metrics.CodeStats)
+	// 全局统计未注册时不再变化
+	assert.Equal(t, int64(0), GetMetrics().ErrorCreated)
+
+	scoped.Reset()
+	assert.Equal(t, int64(0), scoped.Snapshot().ErrorCreated)
+}
+
+func TestAddMetricsSink_Concurrent(t *testing.T) {
+	prev := MetricsSinks()
+	defer SetMetricsSinks(prev...)
+
+	// 并发注册的 sink 不会丢失
+	const n = 64
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			AddMetricsSink(NewErrorMetrics())
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, MetricsSinks(), len(prev)+n)
+}
+
+// 包级变量在 init 之前初始化，此时创建错误同样需要能够记录统计
+var metricsInitErr = ErrorC(96701, "created during package initialization")
+
+func TestMetricsSinks_PackageInit(t *testing.T) {
+	assert.Equal(t, int64(96701), metricsInitErr.NearestCode())
+}
+
+func TestNoopMetricsSink(t *testing.T) {
+	prev := MetricsSinks()
+	defer SetMetricsSinks(prev...)
+
+	SetMetricsSinks(NoopMetricsSink{})
+	assert.Empty(t, MetricsSinks())
+
+	ResetMetrics()
+	_ = Wrap(ErrorC(3002, "inner"), "outer")
+	assert.Equal(t, int64(0), GetMetrics().ErrorCreated)
+
+	// 直接调用也是安全的
+	var sink MetricsSink = NoopMetricsSink{}
+	sink.OnCreated()
+	sink.OnWrapped()
+	sink.OnTraced()
+	sink.OnCode(1)
+	sink.OnTraverse()
+}
+
+func TestMetricsParallelRace(t *testing.T) {
+	prev := MetricsSinks()
+	defer SetMetricsSinks(prev...)
+	windows := EnableWindowStats()
+	defer DisableWindowStats()
+	ResetMetrics()
+	defer ResetMetrics()
+
+	// 并发创建错误的同时读取统计，需要在 go test -race 下通过
+	const workers, perWorker = 16, 500
+	wg := sync.WaitGroup{}
+	stop := make(chan struct{})
+	readers := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					m := GetMetrics()
+					_ = m.LastErrorTime
+					_, _ = TopCodes(time.Minute, 3)
+				}
+			}
+		}()
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				err := Wrap(ErrorC(int64(3100+j%4), "parallel %d", id), "wrap")
+				_ = err.TraverseToRoot(func(error) error { return nil })
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+
+	metrics := GetMetrics()
+	assert.Equal(t, int64(2*workers*perWorker), metrics.ErrorCreated)
+	assert.Equal(t, int64(workers*perWorker), metrics.ErrorWrapped)
+	assert.Equal(t, int64(workers*perWorker), metrics.TraverseOps)
+	assert.Equal(t, int64(workers*perWorker), metrics.ErrorWithCode)
+	for code := int64(3100); code < 3104; code++ {
+		assert.Equal(t, int64(workers*perWorker/4), metrics.CodeStats[code])
+		assert.Equal(t, int64(workers*perWorker/4), windows.Count(time.Minute, code))
+	}
+	assert.False(t, metrics.LastErrorTime.IsZero())
+}
@@ -0,0 +1,327 @@
+package irr
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+type (
+	// CodePicker 决定聚合错误在多个分支的错误码中报告哪一个
+	// codes 按分支顺序给出，只包含非零错误码，且至少有一个元素
+	CodePicker func(codes []int64) int64
+
+	// MultiIrr 聚合多个错误，实现了 Unwrap() []error，可与 errors.Join 互操作
+	// 由于 IRR 接口要求 Unwrap() error，MultiIrr 本身不实现 IRR，
+	// 但提供了遍历与错误码相关的方法，且被 BasicIrr 包装时会遍历其所有分支
+	MultiIrr struct {
+		errs   []error
+		picker CodePicker
+	}
+
+	// Collector 并发安全地收集多个错误，最终聚合为 MultiIrr
+	// 零值可以直接使用
+	Collector struct {
+		// Picker 聚合错误使用的错误码选择策略，为空时使用 PickFirstCode
+		Picker CodePicker
+
+		mu   sync.Mutex
+		errs []error
+	}
+)
+
+var errStopWalk = errors.New("stop walk")
+
+// PickFirstCode 选择第一个分支的错误码，这是默认策略
+func PickFirstCode(codes []int64) int64 {
+	return codes[0]
+}
+
+// PickMaxCode 选择数值最大的错误码
+// 适用于错误码按严重程度递增分段的体系，例如 5xx 比 4xx 更严重
+func PickMaxCode(codes []int64) int64 {
+	val := codes[0]
+	for _, code := range codes[1:] {
+		if code > val {
+			val = code
+		}
+	}
+	return val
+}
+
+// Join 将多个错误聚合为一个错误，nil 会被忽略，全部为 nil 时返回 nil
+// 聚合错误的错误码使用 PickFirstCode 策略
+func Join(errs ...error) error {
+	return JoinWith(nil, errs...)
+}
+
+// JoinWith 与 Join 相同，但使用指定的错误码选择策略
+func JoinWith(picker CodePicker, errs ...error) error {
+	n := 0
+	for _, err := range errs {
+		if err != nil {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	recordErrorCreated()
+	m := &MultiIrr{errs: make([]error, 0, n), picker: picker}
+	for _, err := range errs {
+		if err != nil {
+			m.errs = append(m.errs, err)
+		}
+	}
+	return m
+}
+
+// Error
+// the implementation of error
+func (m *MultiIrr) Error() string {
+	return m.ToString(false, ", ")
+}
+
+// Unwrap 返回所有分支，供 errors.Is / errors.As 使用
+func (m *MultiIrr) Unwrap() []error {
+	return m.errs
+}
+
+// Errors 返回所有分支的副本
+func (m *MultiIrr) Errors() []error {
+	errs := make([]error, len(m.errs))
+	copy(errs, m.errs)
+	return errs
+}
+
+// Len 返回分支数量
+func (m *MultiIrr) Len() int {
+	return len(m.errs)
+}
+
+// ToString 渲染所有分支，分支之间以 "; " 分隔并由 {} 包围
+// 每个分支内部的错误链使用 split 分隔
+func (m *MultiIrr) ToString(printTrace bool, split string) string {
+	sb := strings.Builder{}
+	writeBranchesTo(&sb, m.errs, printTrace, split, false)
+	return sb.String()
+}
+
+// TraverseToRoot 深度优先地遍历自身及所有分支的错误链
+func (m *MultiIrr) TraverseToRoot(fn func(err error) error) (err error) {
+	recordTraverseOp()
+	defer catchTraversePanic(&err)
+	return walkToRoot(m, fn)
+}
+
+// TraverseToSource 深度优先地遍历所有分支，每个分支的末端都会以 isSource 为 true 回调
+// 任一 source 的回调返回非空错误时停止遍历并返回该错误
+func (m *MultiIrr) TraverseToSource(fn func(err error, isSource bool) error) (err error) {
+	recordTraverseOp()
+	defer catchTraversePanic(&err)
+	return walkToSource(m, fn)
+}
+
+// TraverseCode 深度优先地遍历所有分支的错误链及其错误码
+func (m *MultiIrr) TraverseCode(fn func(err error, code int64) error) (err error) {
+	return m.TraverseToRoot(func(err error) error {
+		return fn(err, codeOf(err))
+	})
+}
+
+// CurrentCode 聚合错误本身不携带错误码，总是返回 0
+func (m *MultiIrr) CurrentCode() int64 {
+	return 0
+}
+
+// NearestCode 按选择策略返回各分支最近的有效错误码
+func (m *MultiIrr) NearestCode() int64 {
+	return m.pick(NearestCodeOf)
+}
+
+// RootCode 按选择策略返回各分支根部的错误码
+func (m *MultiIrr) RootCode() int64 {
+	return m.pick(rootCodeOf)
+}
+
+// HasAnyCode 检查任一分支中是否有错误码
+func (m *MultiIrr) HasAnyCode() bool {
+	return m.NearestCode() != 0
+}
+
+func (m *MultiIrr) pick(codeFn func(err error) int64) int64 {
+	codes := make([]int64, 0, len(m.errs))
+	for _, err := range m.errs {
+		if code := codeFn(err); code != 0 {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return 0
+	}
+	if m.picker == nil {
+		return PickFirstCode(codes)
+	}
+	return m.picker(codes)
+}
+
+// Add 添加一个错误，nil 会被忽略
+func (c *Collector) Add(err error) {
+	if err == nil {
+		return
+	}
+	c.mu.Lock()
+	c.errs = append(c.errs, err)
+	c.mu.Unlock()
+}
+
+// Len 返回已收集的错误数量
+func (c *Collector) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.errs)
+}
+
+// Err 返回聚合后的错误，没有收集到错误时返回 nil
+func (c *Collector) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return JoinWith(c.Picker, c.errs...)
+}
+
+// catchTraversePanic 将遍历回调中的 panic 转换为返回的错误
+func catchTraversePanic(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(error); ok {
+			*err = e
+		} else {
+			*err = Wrap(ErrUntypedExecutionFailure, "panic = %v", r)
+		}
+	}
+}
+
+// Walk 深度优先地遍历错误链，遇到 Unwrap() []error 时依次进入每个分支
+// fn 返回 false 时停止遍历并返回 false，否则遍历完成后返回 true
+// 与 IRR.TraverseToRoot 不同，Walk 可以用于任意 error，也不会计入遍历次数
+func Walk(err error, fn func(err error) bool) bool {
+	return walkToRoot(err, func(err error) error {
+		if !fn(err) {
+			return errStopWalk
+		}
+		return nil
+	}) == nil
+}
+
+// walkToRoot 深度优先地遍历错误树，遇到 Unwrap() []error 时依次进入每个分支
+func walkToRoot(err error, fn func(err error) error) error {
+	for err != nil {
+		if e := fn(err); e != nil {
+			return e
+		}
+		if multi, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, branch := range multi.Unwrap() {
+				if e := walkToRoot(branch, fn); e != nil {
+					return e
+				}
+			}
+			return nil
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil
+}
+
+// walkToSource 沿 BasicIrr 链遍历，链尾的非 BasicIrr 错误即为 source
+// 链尾为聚合错误时，依次进入每个分支，每个分支各自有 source
+// 与 TraverseToSource 一致，非 source 回调的返回值会被忽略
+func walkToSource(err error, fn func(err error, isSource bool) error) error {
+	for {
+		switch cur := err.(type) {
+		case *BasicIrr:
+			if cur.inner == nil {
+				return fn(cur, true)
+			}
+			_ = fn(cur, false)
+			err = cur.inner
+		case interface{ Unwrap() []error }:
+			branches := cur.Unwrap()
+			if len(branches) == 0 {
+				return fn(err, true)
+			}
+			_ = fn(err, false)
+			for _, branch := range branches {
+				if e := walkToSource(branch, fn); e != nil {
+					return e
+				}
+			}
+			return nil
+		default:
+			return fn(err, true)
+		}
+	}
+}
+
+// writeErrTo 将任意错误写入 sb，BasicIrr 链与聚合错误会被展开
+func writeErrTo(sb *strings.Builder, err error, printTrace bool, split string, reveal bool) {
+	switch e := err.(type) {
+	case *BasicIrr:
+		e.writeChainTo(sb, printTrace, split, reveal)
+	case interface{ Unwrap() []error }:
+		writeBranchesTo(sb, e.Unwrap(), printTrace, split, reveal)
+	default:
+		sb.WriteString(err.Error())
+	}
+}
+
+func writeBranchesTo(sb *strings.Builder, errs []error, printTrace bool, split string, reveal bool) {
+	sb.WriteRune('{')
+	for i, err := range errs {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		writeErrTo(sb, err, printTrace, split, reveal)
+	}
+	sb.WriteRune('}')
+}
+
+// codeOf 返回错误自身携带的错误码
+func codeOf(err error) int64 {
+	if t, ok := err.(interface{ CurrentCode() int64 }); ok {
+		return t.CurrentCode()
+	} else if t, ok := err.(interface{ GetCode() int64 }); ok {
+		// 兼容其他实现了GetCode的错误类型
+		return t.GetCode()
+	}
+	return 0
+}
+
+// NearestCodeOf 返回错误链中最近的错误码，没有时返回 0
+// 被其他错误包装（如 fmt.Errorf 的 %w）的 IRR 错误以及实现了 GetCode 的错误也能找到
+func NearestCodeOf(err error) int64 {
+	if t, ok := err.(interface{ NearestCode() int64 }); ok {
+		return t.NearestCode()
+	}
+	var val int64
+	_ = walkToRoot(err, func(err error) error {
+		if m, ok := err.(*MultiIrr); ok {
+			val = m.NearestCode()
+			return errStopWalk
+		}
+		if code := codeOf(err); code != 0 {
+			val = code
+			return errStopWalk
+		}
+		return nil
+	})
+	return val
+}
+
+func rootCodeOf(err error) int64 {
+	if t, ok := err.(interface{ RootCode() int64 }); ok {
+		return t.RootCode()
+	}
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		return (&MultiIrr{errs: multi.Unwrap()}).RootCode()
+	}
+	return codeOf(err)
+}
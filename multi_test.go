@@ -0,0 +1,210 @@
+package irr
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoin(t *testing.T) {
+	assert.Nil(t, Join())
+	assert.Nil(t, Join(nil, nil))
+
+	a := ErrorC(400, "name is empty")
+	b := Wrap(ErrorC(422, "age out of range"), "check age")
+	err := Join(a, nil, b)
+
+	m, ok := err.(*MultiIrr)
+	assert.True(t, ok)
+	assert.Equal(t, 2, m.Len())
+	assert.Equal(t, []error{a, b}, m.Unwrap())
+	assert.Equal(t, []error{a, b}, m.Errors())
+	assert.Equal(t, "{code(400), name is empty; check age, code(422), age out of range}", err.Error())
+
+	assert.True(t, errors.Is(err, a))
+	assert.True(t, errors.Is(err, ErrorC(422, "")))
+	assert.False(t, errors.Is(err, ErrorC(500, "")))
+}
+
+func TestMultiIrrCodes(t *testing.T) {
+	a := Wrap(ErrorC(400, "a"), "wrap a").SetCode(401)
+	b := ErrorC(500, "b")
+	c := Error("no code")
+
+	m := Join(c, a, b).(*MultiIrr)
+	assert.Equal(t, int64(0), m.CurrentCode())
+	assert.Equal(t, int64(401), m.NearestCode())
+	assert.Equal(t, int64(400), m.RootCode())
+	assert.True(t, m.HasAnyCode())
+
+	m = JoinWith(PickMaxCode, c, a, b).(*MultiIrr)
+	assert.Equal(t, int64(500), m.NearestCode())
+	assert.Equal(t, int64(500), m.RootCode())
+
+	m = JoinWith(func(codes []int64) int64 { return codes[len(codes)-1] }, a, b, c).(*MultiIrr)
+	assert.Equal(t, int64(500), m.NearestCode())
+
+	m = Join(c, errors.New("std")).(*MultiIrr)
+	assert.Equal(t, int64(0), m.NearestCode())
+	assert.Equal(t, int64(0), m.RootCode())
+	assert.False(t, m.HasAnyCode())
+}
+
+func TestWrapMultiIrr(t *testing.T) {
+	a := ErrorC(400, "a")
+	b := Wrap(errors.New("std b"), "b").SetCode(500)
+	err := Wrap(JoinWith(PickMaxCode, a, b), "batch failed")
+
+	assert.Equal(t, "batch failed, {code(400), a; code(500), b, std b}", err.Error())
+	assert.Equal(t, int64(500), err.NearestCode())
+	assert.Equal(t, int64(400), err.RootCode()) // b 的根部是没有错误码的标准错误
+	assert.Equal(t, int64(400), Wrap(Join(a, b), "batch failed").NearestCode())
+
+	// 外层错误码优先于聚合错误
+	assert.Equal(t, int64(600), Wrap(Join(a, b), "batch failed").SetCode(600).NearestCode())
+
+	var codes []int64
+	_ = err.TraverseCode(func(err error, code int64) error {
+		codes = append(codes, code)
+		return nil
+	})
+	assert.Equal(t, []int64{0, 0, 400, 500, 0}, codes)
+
+	var sources []error
+	_ = err.TraverseToSource(func(err error, isSource bool) error {
+		if isSource {
+			sources = append(sources, err)
+		}
+		return nil
+	})
+	assert.Equal(t, []error{a, errors.Unwrap(b)}, sources)
+}
+
+func TestMultiIrrTraverse(t *testing.T) {
+	a := ErrorC(400, "a")
+	std := errors.New("std")
+	b := fmt.Errorf("fmt: %w", std)
+	m := Join(a, b).(*MultiIrr)
+
+	var visited []error
+	_ = m.TraverseToRoot(func(err error) error {
+		visited = append(visited, err)
+		return nil
+	})
+	assert.Equal(t, []error{m, a, b, std}, visited)
+
+	var sources []error
+	_ = m.TraverseToSource(func(err error, isSource bool) error {
+		if isSource {
+			sources = append(sources, err)
+		}
+		return nil
+	})
+	assert.Equal(t, []error{a, b}, sources)
+
+	var codes []int64
+	_ = m.TraverseCode(func(err error, code int64) error {
+		codes = append(codes, code)
+		return nil
+	})
+	assert.Equal(t, []int64{0, 400, 0, 0}, codes)
+
+	stop := errors.New("stop")
+	err := m.TraverseToSource(func(err error, isSource bool) error {
+		if isSource {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+
+	err = m.TraverseToRoot(func(err error) error {
+		panic("boom")
+	})
+	assert.True(t, errors.Is(err, ErrUntypedExecutionFailure))
+}
+
+func TestStdJoinInterop(t *testing.T) {
+	a := ErrorC(404, "not found")
+	b := ErrorC(500, "internal")
+	err := Wrap(errors.Join(a, b), "batch")
+
+	assert.Equal(t, "batch, {code(404), not found; code(500), internal}", err.Error())
+	assert.Equal(t, int64(404), err.NearestCode())
+	assert.Equal(t, int64(404), err.RootCode())
+	assert.True(t, errors.Is(err, ErrorC(500, "")))
+
+	var sources []error
+	_ = err.TraverseToSource(func(err error, isSource bool) error {
+		if isSource {
+			sources = append(sources, err)
+		}
+		return nil
+	})
+	assert.Equal(t, []error{a, b}, sources)
+
+	// MultiIrr 也可以作为 errors.Join 的分支
+	joined := errors.Join(Join(a), b)
+	assert.True(t, errors.Is(joined, ErrorC(404, "")))
+}
+
+func TestWalk(t *testing.T) {
+	a := ErrorC(404, "not found")
+	b := ErrorC(500, "internal")
+	err := fmt.Errorf("outer: %w", errors.Join(Wrap(a, "load"), b))
+
+	var msgs []string
+	assert.True(t, Walk(err, func(err error) bool {
+		if e, ok := err.(*BasicIrr); ok {
+			msgs = append(msgs, e.Msg)
+		}
+		return true
+	}))
+	assert.Equal(t, []string{"load", "not found", "internal"}, msgs)
+
+	// fn 返回 false 时停止遍历
+	visited := 0
+	assert.False(t, Walk(err, func(err error) bool {
+		visited++
+		return err != a
+	}))
+	assert.Equal(t, 4, visited)
+	assert.True(t, Walk(nil, func(err error) bool { return false }))
+}
+
+func TestNearestCodeOf(t *testing.T) {
+	assert.Equal(t, int64(0), NearestCodeOf(nil))
+	assert.Equal(t, int64(0), NearestCodeOf(errors.New("plain")))
+	assert.Equal(t, int64(404), NearestCodeOf(Wrap(ErrorC(404, "not found"), "load")))
+	// 被标准库错误包装的 IRR 也能找到
+	assert.Equal(t, int64(404), NearestCodeOf(fmt.Errorf("outer: %w", ErrorC(404, "not found"))))
+	assert.Equal(t, int64(500), NearestCodeOf(errors.Join(errors.New("plain"), ErrorC(500, "internal"))))
+}
+
+func TestCollector(t *testing.T) {
+	var c Collector
+	assert.Nil(t, c.Err())
+	assert.Equal(t, 0, c.Len())
+
+	wg := sync.WaitGroup{}
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.Add(ErrorC(int64(i), "error %d", i))
+			c.Add(nil)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 10, c.Len())
+
+	c.Picker = PickMaxCode
+	err := c.Err()
+	m, ok := err.(*MultiIrr)
+	assert.True(t, ok)
+	assert.Equal(t, 10, m.Len())
+	assert.Equal(t, int64(10), m.NearestCode())
+}
@@ -0,0 +1,35 @@
+package irr
+
+import "fmt"
+
+// SetPublicMsg
+// the implementation of IPublicMessenger, the public message is a user-safe
+// message of this layer, it's not included in Error() or ToString, and is
+// exposed to end users by PublicMessage instead of the internal Msg
+func (ir *BasicIrr) SetPublicMsg(formatOrMsg string, args ...any) IRR {
+	if len(args) > 0 {
+		formatOrMsg = fmt.Sprintf(formatOrMsg, args...)
+	}
+	ir.publicMsg = formatOrMsg
+	return ir
+}
+
+// PublicMsg
+// the implementation of IPublicMessenger, it returns the public message of this layer only
+func (ir *BasicIrr) PublicMsg() string {
+	return ir.publicMsg
+}
+
+// PublicMessage 返回错误链中最近的公开消息，聚合错误按深度优先的顺序查找各个分支
+// 链中没有公开消息时返回空字符串，调用方应使用与错误码对应的通用描述，而不是 Error()
+func PublicMessage(err error) string {
+	var msg string
+	_ = walkToRoot(err, func(err error) error {
+		if p, ok := err.(interface{ PublicMsg() string }); ok && p.PublicMsg() != "" {
+			msg = p.PublicMsg()
+			return errStopWalk
+		}
+		return nil
+	})
+	return msg
+}
@@ -0,0 +1,54 @@
+package irr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicMessage(t *testing.T) {
+	assert.Equal(t, "", PublicMessage(nil))
+	assert.Equal(t, "", PublicMessage(errors.New("plain")))
+
+	inner := Error("select failed: no rows").SetPublicMsg("user %s not found", "u1")
+	outer := Wrap(inner, "load profile")
+	assert.Equal(t, "user u1 not found", inner.PublicMsg())
+	assert.Equal(t, "", outer.PublicMsg())
+	assert.Equal(t, "user u1 not found", PublicMessage(outer))
+	assert.Equal(t, "user u1 not found", PublicMessage(fmt.Errorf("rpc: %w", outer)))
+
+	// 公开消息不会出现在内部的诊断输出中
+	assert.Equal(t, "load profile, select failed: no rows", outer.Error())
+	assert.NotContains(t, fmt.Sprintf("%+v", outer), "user u1 not found")
+	assert.Contains(t, fmt.Sprintf("%#v", inner), `Public:"user u1 not found"`)
+
+	// 最近的公开消息优先
+	outer.SetPublicMsg("profile unavailable")
+	assert.Equal(t, "profile unavailable", PublicMessage(outer))
+
+	// 聚合错误按深度优先的顺序查找
+	joined := Join(Error("a"), Error("b").SetPublicMsg("public b"), Error("c").SetPublicMsg("public c"))
+	assert.Equal(t, "public b", PublicMessage(Wrap(joined, "batch")))
+}
+
+func TestPublicMessage_Encoding(t *testing.T) {
+	err := Wrap(ErrorC(404, "no rows").SetPublicMsg("not found"), "load")
+
+	data, _ := MarshalErrorJSON(err)
+	assert.Contains(t, string(data), `"public":"not found"`)
+	decodedJSON, _ := UnmarshalErrorJSON(data)
+	assert.Equal(t, "not found", PublicMessage(decodedJSON.Err))
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, Encode(buf, err))
+	decoded, _ := Decode(buf)
+	assert.Equal(t, "not found", PublicMessage(decoded.Err))
+
+	buf.Reset()
+	slog.New(slog.NewJSONHandler(buf, nil)).Error("failed", slog.Any("error", err))
+	assert.Contains(t, buf.String(), `"public":"not found"`)
+}
@@ -0,0 +1,227 @@
+package irr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// SecretValue 包装敏感值，所有渲染方式（fmt、slog、JSON）都只输出掩码，
+// 原始值只能通过 Reveal 取得
+type SecretValue struct {
+	v any
+}
+
+// DefaultRedactMask 默认的掩码
+const DefaultRedactMask = "***"
+
+var (
+	redactMask    atomic.Pointer[string]
+	sensitiveKeys atomic.Pointer[map[string]struct{}]
+
+	_ fmt.Formatter  = SecretValue{}
+	_ fmt.GoStringer = SecretValue{}
+	_ fmt.Stringer   = SecretValue{}
+	_ slog.LogValuer = SecretValue{}
+	_ json.Marshaler = SecretValue{}
+)
+
+// Secret 将敏感值包装为 SecretValue，可用作 Error/Wrap 等方法的格式化参数或 SetAttr 的值
+//
+//	err := irr.Error("user %s not found", irr.Secret(email))
+//	err.Error()       // user *** not found
+//	err.RevealMsg()   // user alice@example.com not found
+func Secret(v any) SecretValue {
+	return SecretValue{v: v}
+}
+
+// Reveal 返回原始值，只应在受信任的内部输出中使用
+func (s SecretValue) Reveal() any {
+	return s.v
+}
+
+// String 实现 fmt.Stringer，返回掩码
+func (s SecretValue) String() string {
+	return GetRedactMask()
+}
+
+// Format 实现 fmt.Formatter，任何 verb 都只输出掩码
+func (s SecretValue) Format(f fmt.State, _ rune) {
+	_, _ = io.WriteString(f, GetRedactMask())
+}
+
+// GoString 实现 fmt.GoStringer，%#v 同样不会泄漏原始值
+func (s SecretValue) GoString() string {
+	return "irr.Secret(" + GetRedactMask() + ")"
+}
+
+// LogValue 实现 slog.LogValuer，输出掩码
+func (s SecretValue) LogValue() slog.Value {
+	return slog.StringValue(GetRedactMask())
+}
+
+// MarshalJSON 实现 json.Marshaler，输出掩码字符串
+func (s SecretValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(GetRedactMask())
+}
+
+// SetRedactMask 设置全局的掩码，为空时恢复为 DefaultRedactMask
+func SetRedactMask(mask string) {
+	if mask == "" {
+		redactMask.Store(nil)
+		return
+	}
+	redactMask.Store(&mask)
+}
+
+// GetRedactMask 返回全局的掩码
+func GetRedactMask() string {
+	if mask := redactMask.Load(); mask != nil {
+		return *mask
+	}
+	return DefaultRedactMask
+}
+
+// SetSensitiveKeys 设置全局的敏感 key，替换之前的设置，不传参数时清空
+// tag 与 attr 的 key 按大小写敏感的方式匹配，匹配的值在渲染时被替换为掩码，
+// GetTag、AllTags、GetAttr 与 Attrs 依然返回原始值
+func SetSensitiveKeys(keys ...string) {
+	if len(keys) == 0 {
+		sensitiveKeys.Store(nil)
+		return
+	}
+	set := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		set[key] = struct{}{}
+	}
+	sensitiveKeys.Store(&set)
+}
+
+// IsSensitiveKey 判断 tag 或 attr 的 key 是否被标记为敏感
+func IsSensitiveKey(key string) bool {
+	set := sensitiveKeys.Load()
+	if set == nil {
+		return false
+	}
+	_, ok := (*set)[key]
+	return ok
+}
+
+// Redacted 返回用于输出的 tag，敏感 key 的值被替换为掩码
+func (t Tag) Redacted() Tag {
+	if IsSensitiveKey(t.Key) {
+		return Tag{Key: t.Key, Value: GetRedactMask()}
+	}
+	return t
+}
+
+// RevealMsg 返回原始的消息，其中 Secret 参数输出原始值，只应在受信任的内部输出中使用
+func (ir *BasicIrr) RevealMsg() string {
+	if ir.rawMsg != "" {
+		return ir.rawMsg
+	}
+	return ir.Msg
+}
+
+// RevealString 与 ToString 相同，但输出 Secret 参数与敏感 key 的原始值，
+// 只应在受信任的内部输出中使用，非 IRR 错误输出 Error()，ContextualIrr 不输出上下文信息
+func RevealString(err error, printTrace bool, split string) string {
+	if err == nil {
+		return ""
+	}
+	if ce, ok := err.(*ContextualIrr); ok {
+		err = ce.BasicIrr
+	}
+	sb := strings.Builder{}
+	writeErrTo(&sb, err, printTrace, split, true)
+	return sb.String()
+}
+
+// revealMsg 在参数中包含 Secret 时返回使用原始值格式化的消息
+func revealMsg(format string, args []any) string {
+	var revealed []any
+	for i, arg := range args {
+		if s, ok := arg.(SecretValue); ok {
+			if revealed == nil {
+				revealed = make([]any, len(args))
+				copy(revealed, args)
+			}
+			revealed[i] = s.v
+		}
+	}
+	if revealed == nil {
+		return ""
+	}
+	return fmt.Sprintf(format, revealed...)
+}
+
+// redactTags 返回用于输出的 tag，没有敏感 key 时直接返回 tags
+func redactTags(tags []Tag) []Tag {
+	if sensitiveKeys.Load() == nil {
+		return tags
+	}
+	var result []Tag
+	for i, tag := range tags {
+		if !IsSensitiveKey(tag.Key) {
+			continue
+		}
+		if result == nil {
+			result = make([]Tag, len(tags))
+			copy(result, tags)
+		}
+		result[i] = tag.Redacted()
+	}
+	if result == nil {
+		return tags
+	}
+	return result
+}
+
+// redactAttrs 返回用于输出的 attr，敏感 key 的值被替换为掩码，分组中的 key 同样生效
+// Secret 值自身实现了 slog.LogValuer，无需在此处理
+func redactAttrs(attrs []slog.Attr) []slog.Attr {
+	if sensitiveKeys.Load() == nil {
+		return attrs
+	}
+	redacted, _ := redactAttrList(attrs)
+	return redacted
+}
+
+// redactAttrList 没有需要替换的值时返回 attrs 本身
+func redactAttrList(attrs []slog.Attr) ([]slog.Attr, bool) {
+	var result []slog.Attr
+	for i, attr := range attrs {
+		redacted, changed := attr, false
+		if IsSensitiveKey(attr.Key) {
+			redacted, changed = slog.String(attr.Key, GetRedactMask()), true
+		} else if attr.Value.Kind() == slog.KindGroup {
+			var group []slog.Attr
+			if group, changed = redactAttrList(attr.Value.Group()); changed {
+				redacted = slog.Attr{Key: attr.Key, Value: slog.GroupValue(group...)}
+			}
+		}
+		if !changed {
+			continue
+		}
+		if result == nil {
+			result = make([]slog.Attr, len(attrs))
+			copy(result, attrs)
+		}
+		result[i] = redacted
+	}
+	if result == nil {
+		return attrs, false
+	}
+	return result, true
+}
+
+// revealAttrValue 返回 attr 的原始值，Secret 值被展开
+func revealAttrValue(v slog.Value) string {
+	if s, ok := v.Any().(SecretValue); ok {
+		return fmt.Sprint(s.v)
+	}
+	return v.Resolve().String()
+}
@@ -0,0 +1,156 @@
+package irr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret(t *testing.T) {
+	s := Secret("alice@example.com")
+	assert.Equal(t, "alice@example.com", s.Reveal())
+	for _, format := range []string{"%s", "%v", "%+v", "%#v", "%q", "%d", "%x"} {
+		assert.NotContains(t, fmt.Sprintf(format, s), "alice", format)
+	}
+	assert.Equal(t, "***", fmt.Sprint(s))
+
+	data, err := json.Marshal(map[string]any{"email": s})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"email":"***"}`, string(data))
+
+	SetRedactMask("[redacted]")
+	defer SetRedactMask("")
+	assert.Equal(t, "[redacted]", s.String())
+	assert.Equal(t, "[redacted]", s.LogValue().String())
+}
+
+func TestSecret_InMessage(t *testing.T) {
+	err := Wrap(Error("user %s not found, token %v", Secret("alice@example.com"), Secret(42)), "login %s", "web")
+	assert.Equal(t, "login web, user *** not found, token ***", err.Error())
+	assert.NotContains(t, fmt.Sprintf("%+v", err), "alice")
+
+	inner := err.Unwrap().(*BasicIrr)
+	assert.Equal(t, "user alice@example.com not found, token 42", inner.RevealMsg())
+	assert.Equal(t, "login web", err.(*BasicIrr).RevealMsg())
+	assert.Equal(t, "login web, user alice@example.com not found, token 42", RevealString(err, false, ", "))
+
+	// 序列化的结果默认只包含掩码
+	data, _ := MarshalErrorJSON(err)
+	assert.NotContains(t, string(data), "alice")
+	data, _ = json.Marshal(err)
+	assert.NotContains(t, string(data), "alice")
+}
+
+func TestSecret_RoundTrip(t *testing.T) {
+	SetSensitiveKeys("email")
+	defer SetSensitiveKeys()
+
+	err := Error("user %s not found", Secret("alice@example.com"))
+	err.SetTag("email", "alice@example.com")
+	err.SetAttr("password", Secret("p@ss"))
+	err.SetAttr("req", slog.GroupValue(slog.Any("token", Secret(42))))
+	outer := Wrap(err, "login")
+
+	data, mErr := MarshalErrorJSON(outer, WithSecrets())
+	assert.NoError(t, mErr)
+	restoredJSON, uErr := UnmarshalErrorJSON(data)
+	assert.NoError(t, uErr)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, Encode(buf, outer, WithSecrets()))
+	restoredWire, dErr := Decode(buf)
+	assert.NoError(t, dErr)
+
+	for _, restored := range []error{restoredJSON.Err, restoredWire.Err} {
+		// 编解码不丢失原始值，输出时依然脱敏
+		assert.Equal(t, outer.Error(), restored.Error())
+		assert.Equal(t, RevealString(outer, false, ", "), RevealString(restored, false, ", "))
+		inner := errors.Unwrap(restored).(*BasicIrr)
+		assert.Equal(t, "user alice@example.com not found", inner.RevealMsg())
+		assert.Equal(t, []string{"alice@example.com"}, inner.GetTag("email"))
+		val, _ := inner.GetAttr("password")
+		assert.Equal(t, "p@ss", val.Any().(SecretValue).Reveal())
+	}
+
+	// 默认的结果不包含任何原始值
+	data, _ = MarshalErrorJSON(outer)
+	plain, _ := json.Marshal(outer)
+	buf.Reset()
+	assert.NoError(t, Encode(buf, outer))
+	for _, encoded := range []string{string(data), string(plain), buf.String()} {
+		assert.NotContains(t, encoded, "alice")
+		assert.NotContains(t, encoded, "p@ss")
+	}
+	restored, _ := Decode(buf)
+	assert.Equal(t, "user *** not found", errors.Unwrap(restored.Err).(*BasicIrr).RevealMsg())
+}
+
+func TestSensitiveKeys(t *testing.T) {
+	SetSensitiveKeys("email", "token")
+	defer SetSensitiveKeys()
+	assert.True(t, IsSensitiveKey("email"))
+	assert.False(t, IsSensitiveKey("Email"))
+
+	err := Error("login failed")
+	err.SetTag("email", "alice@example.com")
+	err.SetTag("uid", "42")
+	err.SetAttr("token", "t-123")
+	err.SetAttr("req", slog.GroupValue(slog.String("token", "t-456"), slog.Int("size", 3)))
+	err.SetAttr("password", Secret("p@ss"))
+
+	// 原始值依然可以通过访问器取得
+	assert.Equal(t, []string{"alice@example.com"}, err.GetTag("email"))
+	assert.Equal(t, []Tag{{Key: "email", Value: "alice@example.com"}, {Key: "uid", Value: "42"}}, err.(ITagLister).AllTags())
+	val, _ := err.GetAttr("password")
+	assert.Equal(t, "p@ss", val.Any().(SecretValue).Reveal())
+
+	want := "login failed[email:***] [uid:42] [token:***] [req:[token=*** size=3]] [password:***] "
+	assert.Equal(t, want, err.Error())
+	assert.Equal(t, "login failed[email:alice@example.com] [uid:42] [token:t-123] [req:[token=t-456 size=3]] [password:p@ss] ",
+		RevealString(err, false, ", "))
+
+	for _, rendered := range []string{fmt.Sprintf("%+v", err), fmt.Sprintf("%#v", err)} {
+		assert.NotContains(t, rendered, "alice")
+		assert.NotContains(t, rendered, "t-123")
+		assert.NotContains(t, rendered, "t-456")
+		assert.NotContains(t, rendered, "p@ss")
+	}
+
+	buf := &bytes.Buffer{}
+	slog.New(slog.NewJSONHandler(buf, nil)).Error("failed", slog.Any("error", err))
+	assert.Contains(t, buf.String(), `"email":"***"`)
+	assert.Contains(t, buf.String(), `"uid":"42"`)
+	assert.NotContains(t, buf.String(), "t-456")
+	assert.NotContains(t, buf.String(), "p@ss")
+
+	data, _ := MarshalErrorJSON(err)
+	assert.NotContains(t, string(data), "alice")
+	assert.NotContains(t, string(data), "t-123")
+	assert.NotContains(t, string(data), "t-456")
+	assert.NotContains(t, string(data), "p@ss")
+
+	assert.Equal(t, Tag{Key: "email", Value: "***"}, Tag{Key: "email", Value: "x"}.Redacted())
+	assert.Equal(t, Tag{Key: "uid", Value: "x"}, Tag{Key: "uid", Value: "x"}.Redacted())
+
+	// 清空后不再掩码
+	SetSensitiveKeys()
+	assert.Contains(t, err.Error(), "alice@example.com")
+}
+
+func TestRevealString(t *testing.T) {
+	assert.Equal(t, "", RevealString(nil, false, ", "))
+	assert.Equal(t, "plain", RevealString(fmt.Errorf("plain"), false, ", "))
+
+	joined := Join(Error("a %s", Secret("s1")), Error("b"))
+	assert.Equal(t, "{a ***; b}", joined.Error())
+	assert.Equal(t, "{a s1; b}", RevealString(joined, false, ", "))
+
+	ctxErr := ErrorWithContext(context.Background(), "ctx %s", Secret("s2"))
+	assert.Equal(t, "ctx s2", RevealString(ctxErr, false, ", "))
+}
@@ -0,0 +1,254 @@
+// Package retry retries operations by the classification of their IRR error chains.
+//
+// Whether a failed attempt is retried is decided by irr.IsRetryable, so explicit
+// markers (SetRetryClass, SetRetryAfter), codes classified by irr.SetCodeRetryClass
+// or by irc.ApplyRetryClasses, and context.DeadlineExceeded anywhere in
+// the chain are all honored. The delay between attempts grows exponentially with
+// jitter, and a retry-after hint of the chain (irr.RetryAfterOf) is never undercut.
+//
+// All waiting goes through a Clock, tests inject a fake one to run instantly.
+package retry
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+)
+
+type (
+	// Policy describes how many times and how often an operation is attempted.
+	Policy struct {
+		// MaxAttempts is the maximum number of attempts, the first one included.
+		// DefaultPolicy.MaxAttempts is used when it's not positive.
+		MaxAttempts int
+		// InitialDelay is the delay before the second attempt.
+		// DefaultPolicy.InitialDelay is used when it's not positive.
+		InitialDelay time.Duration
+		// MaxDelay caps the delay between attempts, there is no cap when it's 0.
+		MaxDelay time.Duration
+		// Multiplier is the growth factor of the delay after each attempt.
+		// DefaultPolicy.Multiplier is used when it's less than 1.
+		Multiplier float64
+		// Jitter is the fraction of the delay that is randomized, in [0, 1].
+		// A delay d becomes a random value in [d*(1-Jitter), d], 0 disables jitter.
+		Jitter float64
+		// MaxElapsed stops retrying when the next attempt would start later than
+		// MaxElapsed after the first one, there is no limit when it's 0.
+		MaxElapsed time.Duration
+	}
+
+	// Clock tells the time and waits, see SystemClock.
+	Clock interface {
+		Now() time.Time
+		// Sleep waits for d, or returns ctx.Err() as soon as ctx is done.
+		Sleep(ctx context.Context, d time.Duration) error
+	}
+
+	// Option configures Do.
+	Option func(c *config)
+
+	config struct {
+		policy  Policy
+		codes   map[irc.Code]Policy
+		clock   Clock
+		retryIf func(err error) bool
+		onRetry func(attempt int, err error, delay time.Duration)
+	}
+
+	systemClock struct{}
+)
+
+const (
+	// TagAttempt is the tag key of the attempt number, starting from 1, of each
+	// error aggregated by Do.
+	TagAttempt = "retry.attempt"
+)
+
+var (
+	// DefaultPolicy is used by Do when no policy is given.
+	DefaultPolicy = Policy{
+		MaxAttempts:  3,
+		InitialDelay: 100 * time.Millisecond,
+		MaxDelay:     10 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+	}
+
+	// SystemClock is the Clock of the time package, it's the default of Do.
+	SystemClock Clock = systemClock{}
+)
+
+// WithPolicy sets the policy of failures whose code has no policy of its own.
+func WithPolicy(p Policy) Option {
+	return func(c *config) {
+		c.policy = p
+	}
+}
+
+// WithMaxAttempts sets MaxAttempts of the default policy.
+func WithMaxAttempts(n int) Option {
+	return func(c *config) {
+		c.policy.MaxAttempts = n
+	}
+}
+
+// WithMaxElapsed sets MaxElapsed of the default policy.
+func WithMaxElapsed(d time.Duration) Option {
+	return func(c *config) {
+		c.policy.MaxElapsed = d
+	}
+}
+
+// WithCodePolicy sets the policy of failures whose nearest code is code, the
+// policy of the latest failure decides whether and when the next attempt starts.
+func WithCodePolicy(code irc.Code, p Policy) Option {
+	return func(c *config) {
+		if c.codes == nil {
+			c.codes = make(map[irc.Code]Policy)
+		}
+		c.codes[code] = p
+	}
+}
+
+// WithRetryIf replaces irr.IsRetryable to decide whether a failure is retried.
+func WithRetryIf(fn func(err error) bool) Option {
+	return func(c *config) {
+		c.retryIf = fn
+	}
+}
+
+// WithOnRetry sets a callback called before waiting for the next attempt, with
+// the number of the failed attempt, its error and the delay.
+func WithOnRetry(fn func(attempt int, err error, delay time.Duration)) Option {
+	return func(c *config) {
+		c.onRetry = fn
+	}
+}
+
+// WithClock sets the clock used to measure the elapsed time and to wait.
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// Do calls fn until it succeeds, its error is not retryable, the policy is
+// exhausted or ctx is done. It returns nil on success, otherwise an IRR that
+// aggregates the error of every attempt, each tagged with TagAttempt. The
+// nearest code of the result is the nearest code of the final attempt, 0 when
+// it has none, and ctx.Err() is joined when ctx is done while waiting, so
+// errors.Is works for both.
+func Do(ctx context.Context, fn func(ctx context.Context) error, opts ...Option) irr.IRR {
+	c := config{policy: DefaultPolicy, clock: SystemClock, retryIf: irr.IsRetryable}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	start := c.clock.Now()
+	var (
+		errs      []error
+		finalCode int64
+	)
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return stop(append(errs, err), finalCode, attempt-1, "context done")
+		}
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		attemptErr := irr.Wrap(err, "attempt %d", attempt)
+		attemptErr.SetTag(TagAttempt, strconv.Itoa(attempt))
+		errs = append(errs, attemptErr)
+		finalCode = irr.NearestCodeOf(err)
+
+		if !c.retryIf(err) {
+			return stop(errs, finalCode, attempt, "not retryable")
+		}
+		p := c.policyOf(err)
+		if attempt >= p.MaxAttempts {
+			return stop(errs, finalCode, attempt, "max attempts reached")
+		}
+		delay := p.Delay(attempt)
+		if hint, ok := irr.RetryAfterOf(err); ok && hint > delay {
+			delay = hint
+		}
+		if p.MaxElapsed > 0 && c.clock.Now().Add(delay).Sub(start) > p.MaxElapsed {
+			return stop(errs, finalCode, attempt, "max elapsed time reached")
+		}
+		if c.onRetry != nil {
+			c.onRetry(attempt, err, delay)
+		}
+		if err := c.clock.Sleep(ctx, delay); err != nil {
+			return stop(append(errs, err), finalCode, attempt, "context done")
+		}
+	}
+}
+
+// Delay returns the delay after the given failed attempt, starting from 1,
+// jitter included. Zero fields fall back to DefaultPolicy as documented.
+func (p Policy) Delay(attempt int) time.Duration {
+	p = p.withDefaults()
+	delay := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if jitter := math.Min(math.Max(p.Jitter, 0), 1); jitter > 0 {
+		delay -= delay * jitter * rand.Float64()
+	}
+	if delay >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(delay)
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultPolicy.MaxAttempts
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = DefaultPolicy.InitialDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultPolicy.Multiplier
+	}
+	return p
+}
+
+func (c *config) policyOf(err error) Policy {
+	if p, ok := c.codes[irc.Code(irr.NearestCodeOf(err))]; ok {
+		return p.withDefaults()
+	}
+	return c.policy.withDefaults()
+}
+
+// stop aggregates the errors, code is reported whatever the codes of the
+// earlier attempts are.
+func stop(errs []error, code int64, attempts int, reason string) irr.IRR {
+	pickFinal := func([]int64) int64 { return code }
+	return irr.Wrap(irr.JoinWith(pickFinal, errs...), "retry stopped after %d attempts: %s", attempts, reason)
+}
+
+// Now
+// the implementation of Clock
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep
+// the implementation of Clock, the timer is released as soon as ctx is done
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
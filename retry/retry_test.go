@@ -0,0 +1,242 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/khicago/irr"
+	"github.com/khicago/irr/irc"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testCodeBusy irc.Code = 95001
+	testCodeSlow irc.Code = 95002
+)
+
+// fakeClock 不真正等待，Sleep 会立即推进时间
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func noJitter(maxAttempts int) Policy {
+	return Policy{MaxAttempts: maxAttempts, InitialDelay: time.Second, Multiplier: 2}
+}
+
+func TestDo_Success(t *testing.T) {
+	clock := &fakeClock{}
+	calls := 0
+	err := Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return irr.Error("busy").SetRetryClass(irr.ClassRetryable)
+		}
+		return nil
+	}, WithPolicy(noJitter(5)), WithClock(clock))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, clock.sleeps)
+}
+
+func TestDo_Aggregate(t *testing.T) {
+	clock := &fakeClock{}
+	calls := 0
+	err := Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return irr.ErrorC(int64(testCodeBusy)+int64(calls-1), "busy %d", calls).SetRetryClass(irr.ClassTemporary)
+	}, WithPolicy(noJitter(3)), WithClock(clock))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, calls)
+	// 最后一次尝试的错误码
+	assert.Equal(t, int64(testCodeBusy)+2, err.NearestCode())
+	assert.Contains(t, err.Error(), "retry stopped after 3 attempts: max attempts reached")
+
+	var attempts []string
+	_ = err.TraverseToRoot(func(e error) error {
+		if tagger, ok := e.(irr.ITagger); ok {
+			attempts = append(attempts, tagger.GetTag(TagAttempt)...)
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"1", "2", "3"}, attempts)
+	assert.True(t, irr.IsRetryable(err))
+}
+
+func TestDo_FinalAttemptCode(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return irr.ErrorC(int64(testCodeBusy), "busy").SetRetryClass(irr.ClassRetryable)
+		}
+		return irr.Error("reset by peer").SetRetryClass(irr.ClassRetryable)
+	}, WithPolicy(noJitter(2)), WithClock(&fakeClock{}))
+
+	// 最后一次尝试没有错误码，不会报告之前尝试的错误码
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, int64(0), err.NearestCode())
+	// 之前尝试的错误依然被聚合
+	assert.Contains(t, err.Error(), "code(95001), busy")
+}
+
+func TestDo_NotRetryable(t *testing.T) {
+	clock := &fakeClock{}
+	calls := 0
+	cause := irr.Error("bad input")
+	err := Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return cause
+	}, WithClock(clock))
+
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, clock.sleeps)
+	assert.True(t, errors.Is(err, cause))
+	assert.Contains(t, err.Error(), "not retryable")
+
+	// 自定义判断
+	calls = 0
+	_ = Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return cause
+	}, WithClock(clock), WithPolicy(noJitter(4)), WithRetryIf(func(err error) bool { return true }))
+	assert.Equal(t, 4, calls)
+}
+
+func TestDo_DeadlineExceededIsRetried(t *testing.T) {
+	clock := &fakeClock{}
+	calls := 0
+	err := Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return irr.Wrap(context.DeadlineExceeded, "call backend")
+	}, WithPolicy(noJitter(2)), WithClock(clock))
+
+	assert.Equal(t, 2, calls)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, irr.IsTimeout(err))
+}
+
+func TestDo_CodePolicy(t *testing.T) {
+	irr.SetCodeRetryClass(int64(testCodeSlow), irr.ClassRetryable)
+	defer irr.SetCodeRetryClass(int64(testCodeSlow), irr.ClassUnknown)
+
+	clock := &fakeClock{}
+	calls := 0
+	err := Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return irr.Wrap(testCodeSlow.Error("slow"), "query")
+	}, WithPolicy(noJitter(2)), WithClock(clock),
+		WithCodePolicy(testCodeSlow, Policy{MaxAttempts: 4, InitialDelay: time.Minute, Multiplier: 1}))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []time.Duration{time.Minute, time.Minute, time.Minute}, clock.sleeps)
+}
+
+func TestDo_RetryAfter(t *testing.T) {
+	clock := &fakeClock{}
+	calls := 0
+	_ = Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return irr.Error("rate limited").SetRetryAfter(30 * time.Second)
+		}
+		return irr.Error("rate limited").SetRetryAfter(time.Millisecond)
+	}, WithPolicy(noJitter(3)), WithClock(clock))
+
+	// 提示比退避时间长时使用提示，否则使用退避时间
+	assert.Equal(t, []time.Duration{30 * time.Second, 2 * time.Second}, clock.sleeps)
+}
+
+func TestDo_MaxElapsed(t *testing.T) {
+	clock := &fakeClock{}
+	calls := 0
+	var retried []int
+	err := Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return irr.Error("busy").SetRetryClass(irr.ClassRetryable)
+	}, WithPolicy(noJitter(10)), WithMaxElapsed(5*time.Second), WithClock(clock),
+		WithOnRetry(func(attempt int, err error, delay time.Duration) {
+			retried = append(retried, attempt)
+		}))
+
+	// 1s + 2s = 3s，再等 4s 会超过 5s
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []int{1, 2}, retried)
+	assert.Contains(t, err.Error(), "max elapsed time reached")
+}
+
+func TestDo_Context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	err := Do(ctx, func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+	assert.Equal(t, 0, calls)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// 等待期间取消
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	calls = 0
+	err = Do(ctx, func(ctx context.Context) error {
+		calls++
+		return irr.Error("busy").SetRetryClass(irr.ClassRetryable)
+	}, WithClock(blockingClock{}), WithOnRetry(func(int, error, time.Duration) { cancel() }))
+	assert.Equal(t, 1, calls)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Contains(t, err.Error(), "retry stopped after 1 attempts: context done")
+}
+
+// blockingClock 的 Sleep 只会因 ctx 结束而返回
+type blockingClock struct{}
+
+func (blockingClock) Now() time.Time { return time.Time{} }
+func (blockingClock) Sleep(ctx context.Context, d time.Duration) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestSystemClock_Sleep(t *testing.T) {
+	assert.NoError(t, SystemClock.Sleep(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	assert.Equal(t, context.Canceled, SystemClock.Sleep(ctx, time.Hour))
+	assert.Less(t, time.Since(start), time.Minute)
+}
+
+func TestPolicy_Delay(t *testing.T) {
+	p := Policy{InitialDelay: time.Second, Multiplier: 3, MaxDelay: 5 * time.Second}
+	assert.Equal(t, time.Second, p.Delay(1))
+	assert.Equal(t, 3*time.Second, p.Delay(2))
+	assert.Equal(t, 5*time.Second, p.Delay(3))
+
+	// 零值使用默认策略
+	assert.Equal(t, DefaultPolicy.InitialDelay*2, Policy{}.Delay(2))
+	// 没有上限时不会溢出
+	assert.Equal(t, time.Duration(1<<63-1), Policy{Multiplier: 10}.Delay(100))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Delay(2)
+		assert.True(t, d >= 1500*time.Millisecond && d <= 3*time.Second, d)
+	}
+}
@@ -0,0 +1,144 @@
+package irr
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+type (
+	// ISlogCaller 使用 log/slog 输出错误
+	ISlogCaller interface {
+		LogWarnContext(ctx context.Context, logger *slog.Logger) IRR
+		LogErrorContext(ctx context.Context, logger *slog.Logger) IRR
+	}
+
+	// SlogLogger 将 *slog.Logger 适配为 IWarnLogger、IErrorLogger 与 IFatalLogger，
+	// 使现有的 LogWarn/LogError/LogFatal 调用可以直接输出到 slog
+	SlogLogger struct {
+		logger *slog.Logger
+	}
+)
+
+// LevelFatal 是 SlogLogger.Fatal 使用的日志级别
+const LevelFatal = slog.LevelError + 4
+
+var (
+	_ slog.LogValuer = (*BasicIrr)(nil)
+	_ slog.LogValuer = (*MultiIrr)(nil)
+
+	_ IWarnLogger  = (*SlogLogger)(nil)
+	_ IErrorLogger = (*SlogLogger)(nil)
+	_ IFatalLogger = (*SlogLogger)(nil)
+)
+
+// LogValue
+// the implementation of slog.LogValuer, the error is emitted as a group
+// with msg, public, code, retry, tags, attrs, trace and the nested cause
+func (ir *BasicIrr) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, 10)
+	attrs = append(attrs, slog.String("msg", ir.Msg))
+	if ir.publicMsg != "" {
+		attrs = append(attrs, slog.String("public", ir.publicMsg))
+	}
+	if ir.Code != 0 {
+		attrs = append(attrs, slog.Int64("code", ir.Code))
+	}
+	if ir.retryClass != ClassUnknown {
+		attrs = append(attrs, slog.String("retry", ir.retryClass.String()))
+	}
+	if ir.retryAfter > 0 {
+		attrs = append(attrs, slog.Duration("retry_after", ir.retryAfter))
+	}
+	if tags := redactTags(ir.AllTags()); len(tags) > 0 {
+		tagAttrs := make([]slog.Attr, 0, len(tags))
+		for _, tag := range tags {
+			tagAttrs = append(tagAttrs, slog.String(tag.Key, tag.Value))
+		}
+		attrs = append(attrs, slog.Attr{Key: "tags", Value: slog.GroupValue(tagAttrs...)})
+	}
+	if userAttrs := redactAttrs(ir.Attrs()); len(userAttrs) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "attrs", Value: slog.GroupValue(userAttrs...)})
+	}
+	if ir.Trace != nil {
+		attrs = append(attrs, slog.String("trace", ir.Trace.String()))
+		if frames := ir.Trace.frames(); len(frames) > 1 {
+			stack := make([]string, 0, len(frames))
+			for _, frame := range frames {
+				stack = append(stack, frame.FuncName+"@"+frame.FileName+":"+strconv.Itoa(frame.Line))
+			}
+			attrs = append(attrs, slog.Any("stack", stack))
+		}
+	}
+	if ir.inner != nil {
+		attrs = append(attrs, causeAttr(ir.inner))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// LogValue 实现 slog.LogValuer，各分支以其序号为 key
+func (m *MultiIrr) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(m.errs))
+	for i, err := range m.errs {
+		attr := causeAttr(err)
+		attr.Key = strconv.Itoa(i)
+		attrs = append(attrs, attr)
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// causeAttr 能够结构化输出的错误保持结构，其他错误只输出消息
+func causeAttr(err error) slog.Attr {
+	if valuer, ok := err.(slog.LogValuer); ok {
+		return slog.Any("cause", valuer)
+	}
+	return slog.String("cause", err.Error())
+}
+
+// LogWarnContext
+// the implementation of ISlogCaller, the default logger is used when logger is nil
+func (ir *BasicIrr) LogWarnContext(ctx context.Context, logger *slog.Logger) IRR {
+	ir.logContext(ctx, logger, slog.LevelWarn)
+	return ir
+}
+
+// LogErrorContext
+// the implementation of ISlogCaller, the default logger is used when logger is nil
+func (ir *BasicIrr) LogErrorContext(ctx context.Context, logger *slog.Logger) IRR {
+	ir.logContext(ctx, logger, slog.LevelError)
+	return ir
+}
+
+func (ir *BasicIrr) logContext(ctx context.Context, logger *slog.Logger, level slog.Level) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	logger.Log(ctx, level, ir.Error(), slog.Any("error", ir))
+}
+
+// NewSlogLogger 创建 *slog.Logger 的适配器，logger 为空时使用默认 logger
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{logger: logger}
+}
+
+// Warn 实现 IWarnLogger
+func (l *SlogLogger) Warn(args ...any) {
+	l.logger.Warn(fmt.Sprint(args...))
+}
+
+// Error 实现 IErrorLogger
+func (l *SlogLogger) Error(args ...any) {
+	l.logger.Error(fmt.Sprint(args...))
+}
+
+// Fatal 实现 IFatalLogger，以 LevelFatal 级别输出，不会退出进程
+func (l *SlogLogger) Fatal(args ...any) {
+	l.logger.Log(context.Background(), LevelFatal, fmt.Sprint(args...))
+}
@@ -0,0 +1,106 @@
+package irr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newJSONLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func decodeLogLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	line := map[string]any{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line), buf.String())
+	buf.Reset()
+	return line
+}
+
+func TestLogValue(t *testing.T) {
+	inner := Wrap(errors.New("connection refused"), "query").SetCode(5001)
+	err := Trace("load profile")
+	err.SetTag("module", "user")
+	err.SetAttr("uid", 42)
+	outer := Wrap(Join(inner, err), "handle")
+
+	buf := &bytes.Buffer{}
+	newJSONLogger(buf).Info("failed", slog.Any("error", outer))
+	line := decodeLogLine(t, buf)
+
+	e := line["error"].(map[string]any)
+	assert.Equal(t, "handle", e["msg"])
+	assert.Nil(t, e["code"])
+
+	branches := e["cause"].(map[string]any)
+	first := branches["0"].(map[string]any)
+	assert.Equal(t, "query", first["msg"])
+	assert.Equal(t, float64(5001), first["code"])
+	assert.Equal(t, "connection refused", first["cause"])
+
+	second := branches["1"].(map[string]any)
+	assert.Equal(t, "load profile", second["msg"])
+	assert.Equal(t, map[string]any{"module": "user"}, second["tags"])
+	assert.Equal(t, map[string]any{"uid": float64(42)}, second["attrs"])
+	assert.Contains(t, second["trace"], "irr.TestLogValue@")
+	assert.Nil(t, second["stack"])
+}
+
+func TestLogValueFullStack(t *testing.T) {
+	buf := &bytes.Buffer{}
+	newJSONLogger(buf).Info("failed", slog.Any("error", TraceFull("deep")))
+	e := decodeLogLine(t, buf)["error"].(map[string]any)
+	stack := e["stack"].([]any)
+	assert.Greater(t, len(stack), 1)
+	assert.Contains(t, stack[0], "irr.TestLogValueFullStack@")
+}
+
+func TestLogContext(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := newJSONLogger(buf)
+	err := ErrorC(404, "not found")
+
+	ret := err.LogWarnContext(context.Background(), logger)
+	assert.Equal(t, err, ret)
+	line := decodeLogLine(t, buf)
+	assert.Equal(t, "WARN", line["level"])
+	assert.Equal(t, err.Error(), line["msg"])
+	assert.Equal(t, float64(404), line["error"].(map[string]any)["code"])
+
+	ret = err.LogErrorContext(nil, logger) // nil ctx 同样可以使用
+	assert.Equal(t, err, ret)
+	line = decodeLogLine(t, buf)
+	assert.Equal(t, "ERROR", line["level"])
+
+	defaultLogger := slog.Default()
+	defer slog.SetDefault(defaultLogger)
+	slog.SetDefault(logger)
+	err.LogErrorContext(context.Background(), nil)
+	assert.Equal(t, "ERROR", decodeLogLine(t, buf)["level"])
+}
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewSlogLogger(newJSONLogger(buf))
+	err := Error("test err")
+
+	err.LogWarn(l)
+	line := decodeLogLine(t, buf)
+	assert.Equal(t, "WARN", line["level"])
+	assert.Equal(t, err.ToString(true, "\n"), line["msg"])
+
+	err.LogError(l)
+	assert.Equal(t, "ERROR", decodeLogLine(t, buf)["level"])
+
+	l.Fatal("fatal ", 1)
+	line = decodeLogLine(t, buf)
+	assert.Equal(t, "ERROR+4", line["level"])
+	assert.Equal(t, "fatal 1", line["msg"])
+
+	assert.NotNil(t, NewSlogLogger(nil).logger)
+}
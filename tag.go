@@ -0,0 +1,112 @@
+package irr
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+type (
+	// Tag 一个 key/value 形式的标签
+	Tag struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+
+	// TagOrder 决定 tag 在输出与遍历时的顺序
+	TagOrder int32
+
+	// tagStore 按插入顺序保存 tag，index 用于按 key 快速查找
+	// tagStore 一旦发布就不再修改，SetTag 总是创建新的副本
+	tagStore struct {
+		order []Tag
+		index map[string][]string
+	}
+)
+
+const (
+	// TagOrderInsertion 按 SetTag 的调用顺序输出，这是默认策略
+	TagOrderInsertion TagOrder = iota
+	// TagOrderSorted 按 key 排序输出，相同 key 的多个值保持插入顺序
+	TagOrderSorted
+)
+
+var tagOrder atomic.Int32
+
+var _ ITagLister = (*BasicIrr)(nil)
+
+// SetTagOrder 设置全局的 tag 顺序策略，影响 ToString、AllTags 与 JSON 编码
+func SetTagOrder(order TagOrder) {
+	tagOrder.Store(int32(order))
+}
+
+// GetTagOrder 返回全局的 tag 顺序策略
+func GetTagOrder() TagOrder {
+	return TagOrder(tagOrder.Load())
+}
+
+// with 返回添加了新 tag 的副本
+func (s *tagStore) with(key, val string) *tagStore {
+	if s == nil {
+		return &tagStore{
+			order: []Tag{{Key: key, Value: val}},
+			index: map[string][]string{key: {val}},
+		}
+	}
+	newStore := &tagStore{
+		order: make([]Tag, len(s.order), len(s.order)+1),
+		index: make(map[string][]string, len(s.index)+1),
+	}
+	copy(newStore.order, s.order)
+	newStore.order = append(newStore.order, Tag{Key: key, Value: val})
+	for k, v := range s.index {
+		newStore.index[k] = v
+	}
+	// 已发布的切片不可修改，为新值创建新的切片
+	values := s.index[key]
+	newValues := make([]string, len(values), len(values)+1)
+	copy(newValues, values)
+	newStore.index[key] = append(newValues, val)
+	return newStore
+}
+
+// ordered 按全局策略返回 tag，返回值不可修改
+func (s *tagStore) ordered() []Tag {
+	if s == nil {
+		return nil
+	}
+	if GetTagOrder() != TagOrderSorted {
+		return s.order
+	}
+	sorted := make([]Tag, len(s.order))
+	copy(sorted, s.order)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
+
+// AllTags
+// the implementation of ITagLister, tags are returned in the global tag order
+func (ir *BasicIrr) AllTags() []Tag {
+	tags := ir.tags.Load().ordered()
+	if len(tags) == 0 {
+		return nil
+	}
+	result := make([]Tag, len(tags))
+	copy(result, tags)
+	return result
+}
+
+func (ir *BasicIrr) writeTagsTo(sb *strings.Builder, reveal bool) {
+	for _, tag := range ir.tags.Load().ordered() {
+		if !reveal {
+			tag = tag.Redacted()
+		}
+		sb.WriteRune('[')
+		sb.WriteString(tag.Key)
+		sb.WriteRune(':')
+		sb.WriteString(tag.Value)
+		sb.WriteString("] ")
+	}
+}
@@ -0,0 +1,73 @@
+package irr
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagInsertionOrder(t *testing.T) {
+	err := Error("msg")
+	err.SetTag("zone", "z1")
+	err.SetTag("app", "a1")
+	err.SetTag("zone", "z2")
+	err.SetTag("mid", "m1")
+
+	// 多次渲染结果稳定且保持插入顺序
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, "msg[zone:z1] [app:a1] [zone:z2] [mid:m1] ", err.Error())
+	}
+	assert.Equal(t, []Tag{
+		{Key: "zone", Value: "z1"},
+		{Key: "app", Value: "a1"},
+		{Key: "zone", Value: "z2"},
+		{Key: "mid", Value: "m1"},
+	}, err.(ITagLister).AllTags())
+	assert.Equal(t, []string{"z1", "z2"}, err.GetTag("zone"))
+
+	// 返回的是副本
+	tags := err.(ITagLister).AllTags()
+	tags[0].Value = "modified"
+	assert.Equal(t, "z1", err.(ITagLister).AllTags()[0].Value)
+
+	assert.Nil(t, Error("no tags").(ITagLister).AllTags())
+}
+
+func TestTagSortedOrder(t *testing.T) {
+	defer SetTagOrder(TagOrderInsertion)
+
+	err := Error("msg")
+	err.SetTag("zone", "z1")
+	err.SetTag("app", "a1")
+	err.SetTag("zone", "z2")
+
+	SetTagOrder(TagOrderSorted)
+	assert.Equal(t, TagOrderSorted, GetTagOrder())
+	assert.Equal(t, "msg[app:a1] [zone:z1] [zone:z2] ", err.Error())
+	assert.Equal(t, []Tag{
+		{Key: "app", Value: "a1"},
+		{Key: "zone", Value: "z1"},
+		{Key: "zone", Value: "z2"},
+	}, err.(ITagLister).AllTags())
+
+	data, e := json.Marshal(err)
+	assert.NoError(t, e)
+	assert.JSONEq(t, `{"msg":"msg","tags":[{"key":"app","value":"a1"},{"key":"zone","value":"z1"},{"key":"zone","value":"z2"}]}`, string(data))
+
+	SetTagOrder(TagOrderInsertion)
+	assert.Equal(t, "msg[zone:z1] [app:a1] [zone:z2] ", err.Error())
+}
+
+func TestTagStoreImmutable(t *testing.T) {
+	err := Error("msg")
+	err.SetTag("k", "v1")
+	before := err.(*BasicIrr).tags.Load()
+	err.SetTag("k", "v2")
+	err.SetTag("other", "o")
+
+	// 已发布的 store 不会被后续的 SetTag 修改
+	assert.Equal(t, []Tag{{Key: "k", Value: "v1"}}, before.order)
+	assert.Equal(t, []string{"v1"}, before.index["k"])
+	assert.Equal(t, []string{"v1", "v2"}, err.GetTag("k"))
+}
@@ -1,11 +1,13 @@
 package irr
 
 import (
+	"encoding/json"
 	"path"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type (
@@ -14,12 +16,73 @@ type (
 		FileName string `json:"file"`
 		Line     int    `json:"line"`
 
+		// 完整调用栈模式下记录的程序计数器，按需延迟符号化
+		pcs       []uintptr
+		stack     []StackFrame
+		stackOnce sync.Once
+
 		// 缓存字符串表示，避免重复构建
 		cached string
 		once   sync.Once
 	}
+
+	// StackFrame 调用栈中的一帧
+	StackFrame struct {
+		FuncName string `json:"func"`
+		FileName string `json:"file"`
+		Line     int    `json:"line"`
+	}
+
+	// TraceMode 决定 Trace/Track 系列方法捕获调用栈的方式
+	TraceMode int32
+
+	// traceJSON 是 traceInfo 的 JSON 表示
+	traceJSON struct {
+		FuncName string       `json:"func"`
+		FileName string       `json:"file"`
+		Line     int          `json:"line"`
+		Stack    []StackFrame `json:"stack,omitempty"`
+	}
+)
+
+const (
+	// TraceModeSingle 只记录处理位置所在的一帧，这是默认模式，开销最小
+	TraceModeSingle TraceMode = iota
+	// TraceModeFull 记录完整调用栈，深度由 SetFullStackDepth 控制
+	TraceModeFull
 )
 
+// DefaultFullStackDepth 完整调用栈模式下默认的最大深度
+const DefaultFullStackDepth = 32
+
+var (
+	traceMode      atomic.Int32
+	fullStackDepth atomic.Int32
+)
+
+func init() {
+	fullStackDepth.Store(DefaultFullStackDepth)
+}
+
+// SetTraceMode 设置全局的调用栈捕获模式，影响所有 Trace/Track 系列方法
+// TraceFull/TrackFull 不受此设置影响，总是记录完整调用栈
+func SetTraceMode(mode TraceMode) {
+	traceMode.Store(int32(mode))
+}
+
+// GetTraceMode 返回全局的调用栈捕获模式
+func GetTraceMode() TraceMode {
+	return TraceMode(traceMode.Load())
+}
+
+// SetFullStackDepth 设置完整调用栈的最大深度，小于等于 0 时恢复为 DefaultFullStackDepth
+func SetFullStackDepth(depth int) {
+	if depth <= 0 {
+		depth = DefaultFullStackDepth
+	}
+	fullStackDepth.Store(int32(depth))
+}
+
 func (t *traceInfo) String() string {
 	t.once.Do(func() {
 		var sb strings.Builder
@@ -42,6 +105,82 @@ func (t *traceInfo) writeTo(sb *strings.Builder) *strings.Builder {
 	return sb
 }
 
+// writeStackTo writes the frames below the first one, each frame in a new
+// indented line. Nothing is written when only one frame is captured.
+func (t *traceInfo) writeStackTo(sb *strings.Builder) *strings.Builder {
+	frames := t.frames()
+	if len(frames) <= 1 {
+		return sb
+	}
+	for _, frame := range frames[1:] {
+		sb.WriteString("\n\t")
+		sb.WriteString(frame.FuncName)
+		sb.WriteRune('@')
+		sb.WriteString(frame.FileName)
+		sb.WriteRune(':')
+		sb.WriteString(strconv.Itoa(frame.Line))
+	}
+	return sb
+}
+
+// Frames 返回记录的调用栈，第一帧即 FuncName/FileName/Line 所指的位置
+// 单帧模式下只有一帧；完整模式下在首次调用时才进行符号化
+func (t *traceInfo) Frames() []StackFrame {
+	frames := t.frames()
+	result := make([]StackFrame, len(frames))
+	copy(result, frames)
+	return result
+}
+
+func (t *traceInfo) frames() []StackFrame {
+	t.stackOnce.Do(func() {
+		if len(t.pcs) == 0 {
+			return
+		}
+		frames := runtime.CallersFrames(t.pcs)
+		for {
+			frame, more := frames.Next()
+			t.stack = append(t.stack, StackFrame{
+				FuncName: path.Base(frame.Function),
+				FileName: frame.File,
+				Line:     frame.Line,
+			})
+			if !more {
+				break
+			}
+		}
+	})
+	if len(t.stack) == 0 {
+		return []StackFrame{{FuncName: t.FuncName, FileName: t.FileName, Line: t.Line}}
+	}
+	return t.stack
+}
+
+// sameFrame 判断两个 trace 是否指向同一位置
+func (t *traceInfo) sameFrame(o *traceInfo) bool {
+	return t.FuncName == o.FuncName && t.FileName == o.FileName && t.Line == o.Line
+}
+
+// MarshalJSON 完整调用栈模式下会额外输出 stack
+func (t *traceInfo) MarshalJSON() ([]byte, error) {
+	tj := traceJSON{FuncName: t.FuncName, FileName: t.FileName, Line: t.Line}
+	if frames := t.frames(); len(frames) > 1 {
+		tj.Stack = frames
+	}
+	return json.Marshal(tj)
+}
+
+// UnmarshalJSON 解码后的调用栈直接使用 stack 中的帧，无需符号化
+func (t *traceInfo) UnmarshalJSON(data []byte) error {
+	tj := traceJSON{}
+	if err := json.Unmarshal(data, &tj); err != nil {
+		return err
+	}
+	t.FuncName, t.FileName, t.Line = tj.FuncName, tj.FileName, tj.Line
+	t.stack = tj.Stack
+	return nil
+}
+
 var (
 	// 堆栈信息缓存池，复用 traceInfo 对象
 	tracePool = sync.Pool{
@@ -62,17 +201,46 @@ func generateStackTrace(skipMore int) *traceInfo {
 	trace.FuncName = path.Base(funcName)
 	trace.FileName = fileName
 	trace.Line = line
+	trace.pcs = nil
+	trace.stack = nil
+	trace.stackOnce = sync.Once{}
 	trace.cached = ""        // 重置缓存
 	trace.once = sync.Once{} // 重置once
 
 	return trace
 }
 
+// generateFullStackTrace 记录至多 depth 帧的程序计数器，只有第一帧立即符号化
+func generateFullStackTrace(skipMore int, depth int) *traceInfo {
+	pcs := make([]uintptr, depth)
+	// runtime.Callers 的 skip 比 runtime.Caller 多计入其自身
+	n := runtime.Callers(2+skipMore, pcs)
+	if n == 0 {
+		return generateStackTrace(1 + skipMore)
+	}
+	frame, _ := runtime.CallersFrames(pcs[:1]).Next()
+
+	trace := tracePool.Get().(*traceInfo)
+	trace.FuncName = path.Base(frame.Function)
+	trace.FileName = frame.File
+	trace.Line = frame.Line
+	trace.pcs = pcs[:n]
+	trace.stack = nil
+	trace.stackOnce = sync.Once{}
+	trace.cached = ""
+	trace.once = sync.Once{}
+
+	return trace
+}
+
 // 优化：添加释放方法，虽然在错误处理中不常用，但提供了可能性
 func (t *traceInfo) Release() {
 	t.FuncName = ""
 	t.FileName = ""
 	t.Line = 0
+	t.pcs = nil
+	t.stack = nil
+	t.stackOnce = sync.Once{}
 	t.cached = ""
 	t.once = sync.Once{}
 	tracePool.Put(t)
@@ -0,0 +1,83 @@
+package irr
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fullStackHelper() IRR {
+	return TraceFull("deep %s", "error")
+}
+
+func TestTraceFull(t *testing.T) {
+	err := fullStackHelper()
+	trace := err.GetTraceInfo()
+	assert.NotNil(t, trace)
+	assert.Equal(t, "irr.fullStackHelper", trace.FuncName)
+
+	frames := trace.Frames()
+	assert.Greater(t, len(frames), 2)
+	assert.Equal(t, "irr.fullStackHelper", frames[0].FuncName)
+	assert.Equal(t, trace.Line, frames[0].Line)
+	assert.Equal(t, "irr.TestTraceFull", frames[1].FuncName)
+	assert.True(t, strings.HasSuffix(frames[1].FileName, "/trace_test.go"))
+
+	str := err.ToString(true, "\n")
+	lines := strings.Split(str, "\n\t")
+	assert.Equal(t, len(frames), len(lines), str)
+	assert.True(t, strings.HasPrefix(lines[0], "deep error irr.fullStackHelper@"), str)
+	assert.True(t, strings.HasPrefix(lines[1], "irr.TestTraceFull@"), str)
+
+	// 不打印 trace 时与单帧模式一致
+	assert.Equal(t, "deep error", err.Error())
+}
+
+func TestTrackFull(t *testing.T) {
+	inner := Error("inner")
+	err := TrackFull(inner, "outer")
+	assert.Equal(t, inner, err.Unwrap())
+	assert.Equal(t, "irr.TestTrackFull", err.GetTraceInfo().FuncName)
+	assert.Greater(t, len(err.GetTraceInfo().Frames()), 1)
+	assert.Equal(t, "outer, inner", err.Error())
+}
+
+func TestTraceMode(t *testing.T) {
+	defer SetTraceMode(TraceModeSingle)
+	defer SetFullStackDepth(0)
+
+	assert.Equal(t, TraceModeSingle, GetTraceMode())
+	single := Trace("single")
+	assert.Equal(t, 1, len(single.GetTraceInfo().Frames()))
+	assert.NotContains(t, single.ToString(true, "\n"), "\n\t")
+
+	SetTraceMode(TraceModeFull)
+	assert.Equal(t, TraceModeFull, GetTraceMode())
+	full := Trace("full")
+	assert.Equal(t, "irr.TestTraceMode", full.GetTraceInfo().FuncName)
+	assert.Greater(t, len(full.GetTraceInfo().Frames()), 1)
+
+	SetFullStackDepth(2)
+	limited := Track(Error("inner"), "limited")
+	assert.Equal(t, 2, len(limited.GetTraceInfo().Frames()))
+	assert.Equal(t, "irr.TestTraceMode", limited.GetTraceInfo().FuncName)
+}
+
+func TestTraceFullJSON(t *testing.T) {
+	err := fullStackHelper()
+	data, e := json.Marshal(err)
+	assert.NoError(t, e)
+	assert.Contains(t, string(data), `"stack":[`)
+
+	decoded := &BasicIrr{}
+	assert.NoError(t, json.Unmarshal(data, decoded))
+	assert.Equal(t, err.GetTraceInfo().Frames(), decoded.GetTraceInfo().Frames())
+	assert.Equal(t, err.ToString(true, "\n"), decoded.ToString(true, "\n"))
+
+	single := Trace("single")
+	data, e = json.Marshal(single)
+	assert.NoError(t, e)
+	assert.NotContains(t, string(data), `"stack"`)
+}
@@ -2,6 +2,8 @@ package irr
 
 import (
 	"errors"
+	"log/slog"
+	"time"
 )
 
 type (
@@ -27,6 +29,32 @@ type (
 		SetTag(key, value string)
 		GetTag(key string) (values []string)
 	}
+
+	// ITagLister 按全局的 tag 顺序策略列出所有 tag，见 SetTagOrder
+	ITagLister interface {
+		AllTags() []Tag
+	}
+
+	// IPublicMessenger 面向最终用户的公开消息，与内部诊断消息分离
+	IPublicMessenger interface {
+		SetPublicMsg(formatOrMsg string, args ...any) IRR
+		PublicMsg() string
+	}
+
+	// IRetryClassifier 显式的重试分类，见 Classify 与 IsRetryable
+	IRetryClassifier interface {
+		SetRetryClass(class RetryClass) IRR
+		SetRetryAfter(d time.Duration) IRR
+		RetryClass() RetryClass
+		RetryAfter() time.Duration
+	}
+
+	// IAttributor 带类型的属性，与 log/slog 兼容
+	IAttributor interface {
+		SetAttr(key string, val any)
+		GetAttr(key string) (val slog.Value, ok bool)
+		Attrs() []slog.Attr
+	}
 )
 
 type (
@@ -80,7 +108,11 @@ type (
 		ITraverseCoder[int64]
 
 		ITagger
+		IAttributor
+		IPublicMessenger
+		IRetryClassifier
 		ILogCaller
+		ISlogCaller
 
 		ToString(printTrace bool, split string) string
 		GetTraceInfo() *traceInfo
@@ -5,12 +5,25 @@ package irr
 // be `A` when skip= 1 are set, and this is the most general situation.
 // There are another cases that use skip > 1, for example, when implement some
 // basic lib, you may need the stack starts at a frontier caller.
+// The full stack is captured when the global trace mode is TraceModeFull.
 func createTraceInfo(skip int, innerErr error) *traceInfo {
-	t := generateStackTrace(1 + skip)
+	if GetTraceMode() == TraceModeFull {
+		return dedupTraceInfo(generateFullStackTrace(1+skip, int(fullStackDepth.Load())), innerErr)
+	}
+	return dedupTraceInfo(generateStackTrace(1+skip), innerErr)
+}
+
+// createFullTraceInfo is the same as createTraceInfo, but always captures the full stack
+func createFullTraceInfo(skip int, innerErr error) *traceInfo {
+	return dedupTraceInfo(generateFullStackTrace(1+skip, int(fullStackDepth.Load())), innerErr)
+}
+
+// dedupTraceInfo drops t when the inner error has been traced at the same place
+func dedupTraceInfo(t *traceInfo, innerErr error) *traceInfo {
 	if innerErr == nil {
 		return t
 	}
-	if irr, ok := innerErr.(IRR); !ok || irr.GetTraceInfo() == nil || *(irr.GetTraceInfo()) != *t {
+	if irr, ok := innerErr.(IRR); !ok || irr.GetTraceInfo() == nil || !irr.GetTraceInfo().sameFrame(t) {
 		return t
 	}
 	return nil
@@ -0,0 +1,382 @@
+package irr
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// WindowStats 按错误码统计滑动窗口内的错误数量，实现了 MetricsSink
+	// 每个窗口是一个 windowBuckets 个桶的环形缓冲区，精度为窗口长度的 1/windowBuckets
+	// 默认不统计，通过 EnableWindowStats 开启全局实例，也可以通过 AddMetricsSink 注册独立的实例
+	// 没有阈值时记录过程中没有锁
+	WindowStats struct {
+		now     func() time.Time
+		windows []time.Duration
+
+		// codes 保存错误码到 []codeRing 的映射，每个窗口一个 codeRing
+		codes sync.Map
+		// thresholds 保存 []*thresholdState，只整体替换
+		thresholds atomic.Pointer[[]*thresholdState]
+	}
+
+	// CodeCount 错误码及其在窗口内的数量
+	CodeCount struct {
+		Code  int64 `json:"code"`
+		Count int64 `json:"count"`
+	}
+
+	// RateThreshold 错误码频率阈值，窗口内的频率超过 Rate 时调用 OnExceed
+	// 只在越过阈值时触发一次，频率回落到阈值以下后才会再次触发
+	RateThreshold struct {
+		Code     int64         // 错误码，0 表示任意错误码
+		Window   time.Duration // 统计窗口，必须是 WindowStats 的窗口之一
+		Rate     float64       // 每秒错误数
+		OnExceed func(code int64, rate float64)
+	}
+
+	// codeRing 一个错误码在一个窗口内的环形缓冲区，序号过期的桶视为空
+	// 进入新的时间片时用新桶整体替换旧桶，旧桶中并发写入的计数本就已过期
+	codeRing struct {
+		buckets [windowBuckets]atomic.Pointer[windowBucket]
+	}
+
+	// windowBucket 一个时间片的计数
+	windowBucket struct {
+		epoch int64
+		count atomic.Int64
+	}
+
+	thresholdState struct {
+		RateThreshold
+		window int
+
+		mu       sync.Mutex
+		exceeded map[int64]bool
+	}
+)
+
+const windowBuckets = 60
+
+// DefaultWindows 全局 WindowStats 的默认窗口：1 分钟、5 分钟与 1 小时
+var DefaultWindows = []time.Duration{time.Minute, 5 * time.Minute, time.Hour}
+
+// globalWindowStats 全局滑动窗口统计，未开启时为 nil
+var globalWindowStats atomic.Pointer[WindowStats]
+
+// NewWindowStats 创建滑动窗口统计，now 为空时使用 time.Now
+// 不大于 0 的窗口会被忽略，没有窗口时使用 DefaultWindows
+func NewWindowStats(now func() time.Time, windows ...time.Duration) *WindowStats {
+	if now == nil {
+		now = time.Now
+	}
+	valid := make([]time.Duration, 0, len(windows))
+	for _, w := range windows {
+		if w > 0 {
+			valid = append(valid, w)
+		}
+	}
+	if len(valid) == 0 {
+		valid = append(valid, DefaultWindows...)
+	}
+	return &WindowStats{
+		now:     now,
+		windows: valid,
+	}
+}
+
+// EnableWindowStats 开启全局滑动窗口统计并注册为 MetricsSink，默认关闭
+// 没有窗口时使用 DefaultWindows，已开启时替换原有的实例，原有的计数与阈值不会保留
+func EnableWindowStats(windows ...time.Duration) *WindowStats {
+	s := NewWindowStats(nil, windows...)
+	swapGlobalWindowStats(s)
+	return s
+}
+
+// DisableWindowStats 关闭全局滑动窗口统计并取消注册
+func DisableWindowStats() {
+	swapGlobalWindowStats(nil)
+}
+
+// swapGlobalWindowStats 在同一次修改中替换全局实例与它的注册，s 为 nil 时只取消注册
+// 原有的实例按指针匹配，其它 sink 不参与比较，不可比较的 sink 也是安全的
+func swapGlobalWindowStats(s *WindowStats) {
+	metricsSinksMu.Lock()
+	defer metricsSinksMu.Unlock()
+
+	prev := globalWindowStats.Swap(s)
+	sinks := MetricsSinks()
+	list := sinks[:0]
+	for _, sink := range sinks {
+		if ws, ok := sink.(*WindowStats); ok && prev != nil && ws == prev {
+			continue
+		}
+		list = append(list, sink)
+	}
+	if s != nil {
+		list = append(list, s)
+	}
+	storeMetricsSinks(list)
+}
+
+// WindowStatsEnabled 返回是否开启了全局滑动窗口统计
+func WindowStatsEnabled() bool {
+	return globalWindowStats.Load() != nil
+}
+
+// GetWindowStats 获取全局滑动窗口统计，未开启时返回 nil
+func GetWindowStats() *WindowStats {
+	return globalWindowStats.Load()
+}
+
+// TopCodes 返回全局统计中窗口内数量最多的 n 个错误码，见 WindowStats.TopCodes
+// 未开启全局滑动窗口统计时 ok 为 false
+func TopCodes(window time.Duration, n int) (codes []CodeCount, ok bool) {
+	s := globalWindowStats.Load()
+	if s == nil {
+		return nil, false
+	}
+	return s.TopCodes(window, n), true
+}
+
+// CodeRate 返回全局统计中错误码在窗口内的每秒错误数，见 WindowStats.Rate
+// 未开启全局滑动窗口统计时 ok 为 false
+func CodeRate(window time.Duration, code int64) (rate float64, ok bool) {
+	s := globalWindowStats.Load()
+	if s == nil {
+		return 0, false
+	}
+	return s.Rate(window, code), true
+}
+
+// AddRateThreshold 为全局统计添加频率阈值，见 WindowStats.AddThreshold
+// 未开启全局滑动窗口统计时返回 false，重新开启后需要重新添加
+func AddRateThreshold(t RateThreshold) bool {
+	s := globalWindowStats.Load()
+	if s == nil {
+		return false
+	}
+	return s.AddThreshold(t)
+}
+
+// Windows 返回统计的窗口
+func (s *WindowStats) Windows() []time.Duration {
+	return append([]time.Duration(nil), s.windows...)
+}
+
+// OnCode
+// the implementation of MetricsSink
+func (s *WindowStats) OnCode(code int64) {
+	now := s.now()
+	rings := s.ringsOf(code, true)
+	for i := range rings {
+		rings[i].add(s.epoch(i, now))
+	}
+
+	thresholds := s.thresholds.Load()
+	if thresholds == nil {
+		return
+	}
+	for _, t := range *thresholds {
+		if t.Code != 0 && t.Code != code {
+			continue
+		}
+		rate := s.rateOf(rings, t.window, now)
+		if t.cross(code, rate) {
+			// 回调不持有任何锁，回调中可以查询统计
+			t.OnExceed(code, rate)
+		}
+	}
+}
+
+// OnCreated
+// the implementation of MetricsSink, it's not counted
+func (s *WindowStats) OnCreated() {}
+
+// OnWrapped
+// the implementation of MetricsSink, it's not counted
+func (s *WindowStats) OnWrapped() {}
+
+// OnTraced
+// the implementation of MetricsSink, it's not counted
+func (s *WindowStats) OnTraced() {}
+
+// OnTraverse
+// the implementation of MetricsSink, it's not counted
+func (s *WindowStats) OnTraverse() {}
+
+// Count 返回错误码在窗口内的数量，window 不是统计的窗口之一时返回 0
+func (s *WindowStats) Count(window time.Duration, code int64) int64 {
+	i := s.windowIndex(window)
+	if i < 0 {
+		return 0
+	}
+	rings := s.ringsOf(code, false)
+	if rings == nil {
+		return 0
+	}
+	return rings[i].count(s.epoch(i, s.now()))
+}
+
+// Rate 返回错误码在窗口内的每秒错误数，window 不是统计的窗口之一时返回 0
+func (s *WindowStats) Rate(window time.Duration, code int64) float64 {
+	if window <= 0 {
+		return 0
+	}
+	return float64(s.Count(window, code)) / window.Seconds()
+}
+
+// TopCodes 返回窗口内数量最多的 n 个错误码，按数量降序，数量相同时按错误码升序
+// n 不大于 0 时返回全部，数量为 0 的错误码不会返回
+func (s *WindowStats) TopCodes(window time.Duration, n int) []CodeCount {
+	i := s.windowIndex(window)
+	if i < 0 {
+		return nil
+	}
+	epoch := s.epoch(i, s.now())
+	var result []CodeCount
+	s.codes.Range(func(code, rings any) bool {
+		if count := rings.([]codeRing)[i].count(epoch); count > 0 {
+			result = append(result, CodeCount{Code: code.(int64), Count: count})
+		}
+		return true
+	})
+
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Count != result[b].Count {
+			return result[a].Count > result[b].Count
+		}
+		return result[a].Code < result[b].Code
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// AddThreshold 添加频率阈值，Window 不是统计的窗口之一或 OnExceed 为空时返回 false
+func (s *WindowStats) AddThreshold(t RateThreshold) bool {
+	i := s.windowIndex(t.Window)
+	if i < 0 || t.OnExceed == nil {
+		return false
+	}
+	state := &thresholdState{RateThreshold: t, window: i, exceeded: make(map[int64]bool)}
+	for {
+		prev := s.thresholds.Load()
+		var list []*thresholdState
+		if prev != nil {
+			list = append(list, *prev...)
+		}
+		list = append(list, state)
+		if s.thresholds.CompareAndSwap(prev, &list) {
+			return true
+		}
+	}
+}
+
+// Reset 清空所有计数，阈值会保留
+func (s *WindowStats) Reset() {
+	s.codes.Range(func(code, _ any) bool {
+		s.codes.Delete(code)
+		return true
+	})
+	if thresholds := s.thresholds.Load(); thresholds != nil {
+		for _, t := range *thresholds {
+			t.mu.Lock()
+			t.exceeded = make(map[int64]bool)
+			t.mu.Unlock()
+		}
+	}
+}
+
+func (s *WindowStats) windowIndex(window time.Duration) int {
+	for i, w := range s.windows {
+		if w == window {
+			return i
+		}
+	}
+	return -1
+}
+
+// epoch 返回 now 在第 i 个窗口中的时间片序号
+func (s *WindowStats) epoch(i int, now time.Time) int64 {
+	resolution := int64(s.windows[i]) / windowBuckets
+	if resolution <= 0 {
+		resolution = 1
+	}
+	return now.UnixNano() / resolution
+}
+
+// ringsOf 返回错误码的环形缓冲区，create 为 false 且不存在时返回 nil
+func (s *WindowStats) ringsOf(code int64, create bool) []codeRing {
+	rings, ok := s.codes.Load(code)
+	if !ok {
+		if !create {
+			return nil
+		}
+		rings, _ = s.codes.LoadOrStore(code, make([]codeRing, len(s.windows)))
+	}
+	return rings.([]codeRing)
+}
+
+func (s *WindowStats) rateOf(rings []codeRing, i int, now time.Time) float64 {
+	return float64(rings[i].count(s.epoch(i, now))) / s.windows[i].Seconds()
+}
+
+func (r *codeRing) add(epoch int64) {
+	slot := &r.buckets[bucketOf(epoch)]
+	for {
+		b := slot.Load()
+		if b != nil && b.epoch == epoch {
+			b.count.Add(1)
+			return
+		}
+		if b != nil && b.epoch > epoch {
+			// 时钟回拨，桶已被更新的时间片占用
+			return
+		}
+		nb := &windowBucket{epoch: epoch}
+		nb.count.Store(1)
+		if slot.CompareAndSwap(b, nb) {
+			return
+		}
+	}
+}
+
+// count 汇总最近 windowBuckets 个时间片的数量
+func (r *codeRing) count(epoch int64) (total int64) {
+	for i := range r.buckets {
+		if b := r.buckets[i].Load(); b != nil {
+			if age := epoch - b.epoch; age >= 0 && age < windowBuckets {
+				total += b.count.Load()
+			}
+		}
+	}
+	return total
+}
+
+// cross 记录错误码的频率，从不超过阈值变为超过时返回 true
+func (t *thresholdState) cross(code int64, rate float64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if rate <= t.Rate {
+		delete(t.exceeded, code)
+		return false
+	}
+	if t.exceeded[code] {
+		return false
+	}
+	t.exceeded[code] = true
+	return true
+}
+
+func bucketOf(epoch int64) int {
+	slot := epoch % windowBuckets
+	if slot < 0 {
+		slot += windowBuckets
+	}
+	return int(slot)
+}
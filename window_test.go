@@ -0,0 +1,214 @@
+package irr
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNow 可手动推进的时钟
+type fakeNow struct {
+	now time.Time
+}
+
+func (c *fakeNow) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeNow) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestWindowStats_Count(t *testing.T) {
+	clock := &fakeNow{now: time.Unix(1700000000, 0)}
+	s := NewWindowStats(clock.Now, time.Minute, time.Hour, 0)
+	assert.Equal(t, []time.Duration{time.Minute, time.Hour}, s.Windows())
+
+	for i := 0; i < 30; i++ {
+		s.OnCode(5001)
+	}
+	s.OnCode(4001)
+	assert.Equal(t, int64(30), s.Count(time.Minute, 5001))
+	assert.Equal(t, 0.5, s.Rate(time.Minute, 5001))
+	assert.Equal(t, int64(0), s.Count(5*time.Minute, 5001), "未配置的窗口")
+	assert.Equal(t, 0.0, s.Rate(0, 5001))
+
+	// 30 秒后仍在 1 分钟窗口内
+	clock.Advance(30 * time.Second)
+	s.OnCode(5001)
+	assert.Equal(t, int64(31), s.Count(time.Minute, 5001))
+
+	// 再过 31 秒，第一批已移出 1 分钟窗口，但仍在 1 小时窗口内
+	clock.Advance(31 * time.Second)
+	assert.Equal(t, int64(1), s.Count(time.Minute, 5001))
+	assert.Equal(t, int64(31), s.Count(time.Hour, 5001))
+	assert.Equal(t, int64(1), s.Count(time.Hour, 4001))
+
+	clock.Advance(2 * time.Hour)
+	assert.Equal(t, int64(0), s.Count(time.Hour, 5001))
+	assert.Empty(t, s.TopCodes(time.Hour, 0))
+
+	// 桶被复用时会先清零
+	s.OnCode(5001)
+	assert.Equal(t, int64(1), s.Count(time.Minute, 5001))
+	assert.Equal(t, int64(1), s.Count(time.Hour, 5001))
+}
+
+func TestWindowStats_TopCodes(t *testing.T) {
+	clock := &fakeNow{now: time.Unix(1700000000, 0)}
+	s := NewWindowStats(clock.Now)
+	assert.Equal(t, DefaultWindows, s.Windows())
+
+	counts := map[int64]int{5001: 5, 5002: 2, 4001: 2, 3001: 1}
+	for code, n := range counts {
+		for i := 0; i < n; i++ {
+			s.OnCode(code)
+		}
+	}
+	assert.Equal(t, []CodeCount{{5001, 5}, {4001, 2}, {5002, 2}}, s.TopCodes(time.Minute, 3))
+	assert.Len(t, s.TopCodes(time.Hour, 0), 4)
+	assert.Nil(t, s.TopCodes(time.Second, 3))
+
+	// 5 分钟后只剩新的错误
+	clock.Advance(5 * time.Minute)
+	s.OnCode(3001)
+	assert.Equal(t, []CodeCount{{3001, 1}}, s.TopCodes(time.Minute, 3))
+	assert.Equal(t, CodeCount{5001, 5}, s.TopCodes(time.Hour, 1)[0])
+
+	s.Reset()
+	assert.Empty(t, s.TopCodes(time.Hour, 0))
+}
+
+func TestWindowStats_Threshold(t *testing.T) {
+	clock := &fakeNow{now: time.Unix(1700000000, 0)}
+	s := NewWindowStats(clock.Now, time.Minute)
+
+	type fired struct {
+		code int64
+		rate float64
+	}
+	var events []fired
+	onExceed := func(code int64, rate float64) {
+		events = append(events, fired{code, rate})
+		// 回调中可以查询统计
+		_ = s.Count(time.Minute, code)
+	}
+	assert.False(t, s.AddThreshold(RateThreshold{Code: 5001, Window: time.Hour, Rate: 1, OnExceed: onExceed}))
+	assert.False(t, s.AddThreshold(RateThreshold{Code: 5001, Window: time.Minute, Rate: 1}))
+	assert.True(t, s.AddThreshold(RateThreshold{Code: 5001, Window: time.Minute, Rate: 0.05, OnExceed: onExceed}))
+
+	// 0.05/s 即每分钟 3 次，第 4 次越过阈值
+	for i := 0; i < 10; i++ {
+		s.OnCode(5001)
+		s.OnCode(4001)
+	}
+	assert.Equal(t, []fired{{5001, 4.0 / 60}}, events)
+
+	// 回落后再次越过会重新触发
+	clock.Advance(2 * time.Minute)
+	for i := 0; i < 4; i++ {
+		s.OnCode(5001)
+	}
+	assert.Len(t, events, 2)
+
+	// 0 表示任意错误码
+	events = nil
+	assert.True(t, s.AddThreshold(RateThreshold{Window: time.Minute, Rate: 0, OnExceed: onExceed}))
+	s.OnCode(4002)
+	s.OnCode(4002)
+	assert.Equal(t, []fired{{4002, 1.0 / 60}}, events)
+}
+
+func TestWindowStats_Global(t *testing.T) {
+	ResetMetrics()
+	defer ResetMetrics()
+
+	// 默认关闭，全局查询报告未开启
+	assert.False(t, WindowStatsEnabled())
+	assert.Nil(t, GetWindowStats())
+	_ = ErrorC(96601, "ignored")
+	codes, ok := TopCodes(time.Minute, 1)
+	assert.False(t, ok)
+	assert.Nil(t, codes)
+	_, ok = CodeRate(time.Minute, 96601)
+	assert.False(t, ok)
+	assert.False(t, AddRateThreshold(RateThreshold{Window: time.Minute, OnExceed: func(int64, float64) {}}))
+
+	prev := MetricsSinks()
+	defer SetMetricsSinks(prev...)
+	s := EnableWindowStats()
+	defer DisableWindowStats()
+	assert.True(t, WindowStatsEnabled())
+	assert.Equal(t, s, GetWindowStats())
+	assert.Equal(t, DefaultWindows, s.Windows())
+	assert.Equal(t, append(prev, s), MetricsSinks())
+
+	for i := 0; i < 3; i++ {
+		_ = ErrorC(96601, "spike")
+	}
+	_ = ErrorC(96602, "once")
+	codes, ok = TopCodes(time.Minute, 1)
+	assert.True(t, ok)
+	assert.Equal(t, []CodeCount{{96601, 3}}, codes)
+	rate, ok := CodeRate(5*time.Minute, 96601)
+	assert.True(t, ok)
+	assert.Equal(t, 3.0/300, rate)
+	assert.False(t, AddRateThreshold(RateThreshold{Window: time.Second}))
+
+	// 实现了 MetricsSink，其它事件不计数
+	var sink MetricsSink = GetWindowStats()
+	sink.OnCreated()
+	sink.OnWrapped()
+	sink.OnTraced()
+	sink.OnTraverse()
+
+	ResetMetrics()
+	codes, _ = TopCodes(time.Minute, 0)
+	assert.Empty(t, codes)
+
+	// 重新开启时替换原有的实例
+	replaced := EnableWindowStats(time.Second)
+	assert.Equal(t, append(prev, replaced), MetricsSinks())
+	assert.Equal(t, []time.Duration{time.Second}, GetWindowStats().Windows())
+
+	DisableWindowStats()
+	assert.False(t, WindowStatsEnabled())
+	assert.Equal(t, prev, MetricsSinks())
+}
+
+// uncomparableSink 是不可比较的 MetricsSink 值
+type uncomparableSink struct {
+	NoopMetricsSink
+	codes []int64
+}
+
+func TestWindowStats_GlobalConcurrent(t *testing.T) {
+	prev := MetricsSinks()
+	defer SetMetricsSinks(prev...)
+	defer DisableWindowStats()
+	AddMetricsSink(uncomparableSink{codes: []int64{1}})
+
+	// 并发开启后只保留最后一个实例的注册
+	wg := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			EnableWindowStats()
+		}()
+	}
+	wg.Wait()
+
+	var registered []*WindowStats
+	for _, sink := range MetricsSinks() {
+		if ws, ok := sink.(*WindowStats); ok {
+			registered = append(registered, ws)
+		}
+	}
+	assert.Equal(t, []*WindowStats{GetWindowStats()}, registered)
+
+	DisableWindowStats()
+	assert.Equal(t, append(prev, uncomparableSink{codes: []int64{1}}), MetricsSinks())
+}
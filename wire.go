@@ -0,0 +1,464 @@
+package irr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// 二进制编码格式
+//
+//	stream  = magic("IRR") version(1 byte) uvarint(len) node
+//	node    = *field
+//	field   = uvarint(number<<3 | type) value
+//	value   = uvarint                   (type 0)
+//	        | uvarint(len) bytes        (type 2)
+//
+// 有符号整数使用 zigzag 编码。解码时跳过未知编号的字段，
+// 因此新增字段不需要提升版本，只有不兼容的改动才会提升 WireVersion
+
+const (
+	// WireVersion 是 Encode 写出的格式版本，Decode 拒绝更高的版本
+	WireVersion byte = 1
+
+	// MaxWireSize 是 Decode 接受的最大负载长度
+	MaxWireSize = 16 << 20
+
+	// maxWireDepth 限制嵌套节点的深度，避免恶意输入耗尽调用栈
+	maxWireDepth = 1024
+
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// 节点的种类
+const (
+	wireKindBasic = 1 + iota
+	wireKindMulti
+	wireKindForeign
+)
+
+// 节点的字段编号，已分配的编号不可复用
+const (
+	wireNodeKind    = 1
+	wireNodeCode    = 2
+	wireNodeCodeSet = 3
+	wireNodeMsg     = 4
+	wireNodeTag     = 5
+	wireNodeAttr    = 6
+	wireNodeTrace   = 7
+	wireNodeInner   = 8
+	wireNodeBranch  = 9
+	wireNodePublic  = 10
+	wireNodeRetry   = 11
+	wireNodeWait    = 12
+	wireNodeRawMsg  = 13
+)
+
+// tag、attr、trace 与栈帧内部的字段编号
+const (
+	wireTagKey   = 1
+	wireTagValue = 2
+
+	wireAttrKey   = 1
+	wireAttrKind  = 2
+	wireAttrValue = 3
+
+	wireTraceFunc  = 1
+	wireTraceFile  = 2
+	wireTraceLine  = 3
+	wireTraceFrame = 4
+)
+
+var (
+	wireMagic = [3]byte{'I', 'R', 'R'}
+
+	// ErrInvalidWire 表示二进制数据不是合法的错误编码
+	ErrInvalidWire = errors.New("invalid irr wire encoding")
+	// ErrUnsupportedWireVersion 表示数据由更新的、不兼容的版本写出
+	ErrUnsupportedWireVersion = errors.New("unsupported irr wire version")
+)
+
+// Encode 将错误链以二进制格式写入 w，内容与 MarshalErrorJSON 相同，
+// 包括每一层的 code、msg、tags、attrs 与 trace，聚合错误的所有分支也会被写出
+// 与 Error() 一样，Secret 与敏感 key 的值默认只保留掩码，见 WithSecrets
+// err 为 nil 时写出一个空的负载，Decode 会还原为 nil
+func Encode(w io.Writer, err error, opts ...CodecOption) error {
+	var payload []byte
+	if err != nil {
+		payload = appendWireNode(nil, newJSONNode(err, newCodecConfig(opts)))
+	}
+	buf := make([]byte, 0, len(wireMagic)+1+binary.MaxVarintLen64+len(payload))
+	buf = append(buf, wireMagic[:]...)
+	buf = append(buf, WireVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(payload)))
+	buf = append(buf, payload...)
+	_, wErr := w.Write(buf)
+	return wErr
+}
+
+// Decode 从 r 读取一个由 Encode 写出的错误链
+// 解码得到的错误链在 Decoded.Err 中，返回的 error 只表示解码过程中的失败
+// Decode 只读取一个错误所需的字节，因此同一个流中可以连续编码多个错误
+func Decode(r io.Reader) (Decoded, error) {
+	var header [len(wireMagic) + 1]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Decoded{}, err
+	}
+	if [3]byte(header[:3]) != wireMagic {
+		return Decoded{}, Wrap(ErrInvalidWire, "bad magic")
+	}
+	if version := header[3]; version == 0 || version > WireVersion {
+		return Decoded{}, Wrap(ErrUnsupportedWireVersion, "version %d", version)
+	}
+
+	size, err := binary.ReadUvarint(byteReaderOf(r))
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return Decoded{}, err
+	}
+	if size > MaxWireSize {
+		return Decoded{}, Wrap(ErrInvalidWire, "payload of %d bytes exceeds the limit", size)
+	}
+	if size == 0 {
+		return Decoded{}, nil
+	}
+	// 长度前缀不可信，按实际读到的数据增长缓冲区，而不是预先分配 size 字节
+	payload := bytes.Buffer{}
+	n, err := payload.ReadFrom(io.LimitReader(r, int64(size)))
+	if err != nil {
+		return Decoded{}, err
+	}
+	if uint64(n) < size {
+		return Decoded{}, io.ErrUnexpectedEOF
+	}
+
+	node, err := decodeWireNode(payload.Bytes(), 0)
+	if err != nil {
+		return Decoded{}, err
+	}
+	return Decoded{Err: node.toError()}, nil
+}
+
+func appendWireNode(buf []byte, n *jsonNode) []byte {
+	switch {
+	case n.Errors != nil:
+		buf = appendWireVarint(buf, wireNodeKind, wireKindMulti)
+		for _, branch := range n.Errors {
+			buf = appendWireBytes(buf, wireNodeBranch, appendWireNode(nil, branch))
+		}
+		return buf
+	case n.Foreign:
+		buf = appendWireVarint(buf, wireNodeKind, wireKindForeign)
+	default:
+		buf = appendWireVarint(buf, wireNodeKind, wireKindBasic)
+	}
+
+	if n.Code != 0 {
+		buf = appendWireVarint(buf, wireNodeCode, zigzag(n.Code))
+	}
+	if n.CodeSet {
+		buf = appendWireVarint(buf, wireNodeCodeSet, 1)
+	}
+	if n.Msg != "" {
+		buf = appendWireString(buf, wireNodeMsg, n.Msg)
+	}
+	if n.RawMsg != "" {
+		buf = appendWireString(buf, wireNodeRawMsg, n.RawMsg)
+	}
+	if n.Public != "" {
+		buf = appendWireString(buf, wireNodePublic, n.Public)
+	}
+	if class := parseRetryClass(n.Retry); class != ClassUnknown {
+		buf = appendWireVarint(buf, wireNodeRetry, uint64(class))
+	}
+	if n.RetryAfter != 0 {
+		buf = appendWireVarint(buf, wireNodeWait, zigzag(int64(n.RetryAfter)))
+	}
+	for _, tag := range n.Tags {
+		var field []byte
+		field = appendWireString(field, wireTagKey, tag.Key)
+		field = appendWireString(field, wireTagValue, tag.Value)
+		buf = appendWireBytes(buf, wireNodeTag, field)
+	}
+	for _, attr := range n.Attrs {
+		var field []byte
+		field = appendWireString(field, wireAttrKey, attr.Key)
+		field = appendWireString(field, wireAttrKind, attr.Kind)
+		field = appendWireBytes(field, wireAttrValue, attr.Value)
+		buf = appendWireBytes(buf, wireNodeAttr, field)
+	}
+	if n.Trace != nil {
+		buf = appendWireBytes(buf, wireNodeTrace, appendWireTrace(nil, n.Trace))
+	}
+	if n.Inner != nil {
+		buf = appendWireBytes(buf, wireNodeInner, appendWireNode(nil, n.Inner))
+	}
+	return buf
+}
+
+// appendWireTrace 与 MarshalJSON 一致，只有完整调用栈才写出栈帧
+func appendWireTrace(buf []byte, t *traceInfo) []byte {
+	buf = appendWireString(buf, wireTraceFunc, t.FuncName)
+	buf = appendWireString(buf, wireTraceFile, t.FileName)
+	buf = appendWireVarint(buf, wireTraceLine, zigzag(int64(t.Line)))
+	if frames := t.frames(); len(frames) > 1 {
+		for _, frame := range frames {
+			var field []byte
+			field = appendWireString(field, wireTraceFunc, frame.FuncName)
+			field = appendWireString(field, wireTraceFile, frame.FileName)
+			field = appendWireVarint(field, wireTraceLine, zigzag(int64(frame.Line)))
+			buf = appendWireBytes(buf, wireTraceFrame, field)
+		}
+	}
+	return buf
+}
+
+func decodeWireNode(data []byte, depth int) (*jsonNode, error) {
+	if depth > maxWireDepth {
+		return nil, Wrap(ErrInvalidWire, "nodes nested deeper than %d", maxWireDepth)
+	}
+	n := &jsonNode{}
+	kind := uint64(wireKindBasic)
+	err := walkWireFields(data, func(num, typ, val uint64, payload []byte) error {
+		var err error
+		switch num {
+		case wireNodeKind:
+			kind, err = val, expectWireType(num, typ, wireVarint)
+		case wireNodeCode:
+			n.Code, err = unzigzag(val), expectWireType(num, typ, wireVarint)
+		case wireNodeCodeSet:
+			n.CodeSet, err = val != 0, expectWireType(num, typ, wireVarint)
+		case wireNodeMsg:
+			n.Msg, err = string(payload), expectWireType(num, typ, wireBytes)
+		case wireNodeRawMsg:
+			n.RawMsg, err = string(payload), expectWireType(num, typ, wireBytes)
+		case wireNodePublic:
+			n.Public, err = string(payload), expectWireType(num, typ, wireBytes)
+		case wireNodeRetry:
+			n.Retry, err = RetryClass(val).String(), expectWireType(num, typ, wireVarint)
+		case wireNodeWait:
+			n.RetryAfter, err = time.Duration(unzigzag(val)), expectWireType(num, typ, wireVarint)
+		case wireNodeTag:
+			if err = expectWireType(num, typ, wireBytes); err == nil {
+				var tag Tag
+				tag, err = decodeWireTag(payload)
+				n.Tags = append(n.Tags, tag)
+			}
+		case wireNodeAttr:
+			if err = expectWireType(num, typ, wireBytes); err == nil {
+				var attr jsonAttr
+				attr, err = decodeWireAttr(payload)
+				n.Attrs = append(n.Attrs, attr)
+			}
+		case wireNodeTrace:
+			if err = expectWireType(num, typ, wireBytes); err == nil {
+				n.Trace, err = decodeWireTrace(payload)
+			}
+		case wireNodeInner:
+			if err = expectWireType(num, typ, wireBytes); err == nil {
+				n.Inner, err = decodeWireNode(payload, depth+1)
+			}
+		case wireNodeBranch:
+			if err = expectWireType(num, typ, wireBytes); err == nil {
+				var branch *jsonNode
+				branch, err = decodeWireNode(payload, depth+1)
+				n.Errors = append(n.Errors, branch)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case wireKindMulti:
+		if n.Errors == nil {
+			n.Errors = []*jsonNode{}
+		}
+		n.Inner = nil
+	case wireKindForeign:
+		n.Foreign = true
+		n.Errors = nil
+	default:
+		// 未知的种类按 BasicIrr 解码，保留消息与链接
+		n.Errors = nil
+	}
+	return n, nil
+}
+
+func decodeWireTag(data []byte) (tag Tag, err error) {
+	err = walkWireFields(data, func(num, typ, _ uint64, payload []byte) error {
+		switch num {
+		case wireTagKey:
+			tag.Key = string(payload)
+			return expectWireType(num, typ, wireBytes)
+		case wireTagValue:
+			tag.Value = string(payload)
+			return expectWireType(num, typ, wireBytes)
+		}
+		return nil
+	})
+	return tag, err
+}
+
+func decodeWireAttr(data []byte) (attr jsonAttr, err error) {
+	err = walkWireFields(data, func(num, typ, _ uint64, payload []byte) error {
+		switch num {
+		case wireAttrKey:
+			attr.Key = string(payload)
+			return expectWireType(num, typ, wireBytes)
+		case wireAttrKind:
+			attr.Kind = string(payload)
+			return expectWireType(num, typ, wireBytes)
+		case wireAttrValue:
+			attr.Value = append([]byte(nil), payload...)
+			return expectWireType(num, typ, wireBytes)
+		}
+		return nil
+	})
+	return attr, err
+}
+
+func decodeWireTrace(data []byte) (*traceInfo, error) {
+	t := &traceInfo{}
+	err := walkWireFields(data, func(num, typ, val uint64, payload []byte) error {
+		switch num {
+		case wireTraceFunc:
+			t.FuncName = string(payload)
+			return expectWireType(num, typ, wireBytes)
+		case wireTraceFile:
+			t.FileName = string(payload)
+			return expectWireType(num, typ, wireBytes)
+		case wireTraceLine:
+			t.Line = int(unzigzag(val))
+			return expectWireType(num, typ, wireVarint)
+		case wireTraceFrame:
+			if err := expectWireType(num, typ, wireBytes); err != nil {
+				return err
+			}
+			frame, err := decodeWireFrame(payload)
+			t.stack = append(t.stack, frame)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func decodeWireFrame(data []byte) (frame StackFrame, err error) {
+	err = walkWireFields(data, func(num, typ, val uint64, payload []byte) error {
+		switch num {
+		case wireTraceFunc:
+			frame.FuncName = string(payload)
+			return expectWireType(num, typ, wireBytes)
+		case wireTraceFile:
+			frame.FileName = string(payload)
+			return expectWireType(num, typ, wireBytes)
+		case wireTraceLine:
+			frame.Line = int(unzigzag(val))
+			return expectWireType(num, typ, wireVarint)
+		}
+		return nil
+	})
+	return frame, err
+}
+
+// walkWireFields 依次回调 data 中的每个字段，varint 字段的值在 val 中，
+// 长度前缀字段的内容在 payload 中；未知编号的字段由回调忽略即可跳过
+func walkWireFields(data []byte, fn func(num, typ, val uint64, payload []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return Wrap(ErrInvalidWire, "bad field key")
+		}
+		data = data[n:]
+		num, typ := key>>3, key&7
+		if num == 0 {
+			return Wrap(ErrInvalidWire, "field number 0")
+		}
+
+		var val uint64
+		var payload []byte
+		switch typ {
+		case wireVarint:
+			if val, n = binary.Uvarint(data); n <= 0 {
+				return Wrap(ErrInvalidWire, "bad varint of field %d", num)
+			}
+			data = data[n:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return Wrap(ErrInvalidWire, "bad length of field %d", num)
+			}
+			payload = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			return Wrap(ErrInvalidWire, "unknown wire type %d of field %d", typ, num)
+		}
+		if err := fn(num, typ, val, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func expectWireType(num, typ, want uint64) error {
+	if typ != want {
+		return Wrap(ErrInvalidWire, "wire type %d of field %d, want %d", typ, num, want)
+	}
+	return nil
+}
+
+func appendWireVarint(buf []byte, num int, val uint64) []byte {
+	buf = binary.AppendUvarint(buf, uint64(num)<<3|wireVarint)
+	return binary.AppendUvarint(buf, val)
+}
+
+func appendWireBytes(buf []byte, num int, val []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(num)<<3|wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(val)))
+	return append(buf, val...)
+}
+
+func appendWireString(buf []byte, num int, val string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(num)<<3|wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(val)))
+	return append(buf, val...)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// byteReaderOf 按字节读取长度前缀，不会读取超出当前错误的数据
+func byteReaderOf(r io.Reader) io.ByteReader {
+	if br, ok := r.(io.ByteReader); ok {
+		return br
+	}
+	return &singleByteReader{r: r}
+}
+
+type singleByteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (s *singleByteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(s.r, s.buf[:]); err != nil {
+		return 0, err
+	}
+	return s.buf[0], nil
+}
@@ -0,0 +1,222 @@
+package irr
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func wireRoundTrip(t *testing.T, err error) error {
+	buf := &bytes.Buffer{}
+	assert.NoError(t, Encode(buf, err))
+	decoded, dErr := Decode(buf)
+	assert.NoError(t, dErr)
+	assert.Equal(t, 0, buf.Len())
+	return decoded.Err
+}
+
+func TestEncodeDecode(t *testing.T) {
+	root := errors.New("connection refused")
+	inner := TrackFull(fmt.Errorf("dial: %w", root), "query user").SetCode(-5001)
+	inner.SetTag("db", "users")
+	inner.SetTag("db", "replica")
+	inner.SetAttr("elapsed", 3*time.Second)
+	inner.SetAttr("retries", 3)
+	outer := Wrap(inner, "load profile")
+	outer.SetTag("uid", "42")
+
+	decoded := wireRoundTrip(t, outer)
+	assert.Equal(t, outer.Error(), decoded.Error())
+	assert.Equal(t, outer.ToString(true, "\n"), decoded.(IRR).ToString(true, "\n"))
+	assert.Equal(t, int64(-5001), decoded.(IRR).NearestCode())
+	assert.True(t, errors.Is(decoded, ErrorC(-5001, "")))
+
+	decodedInner := errors.Unwrap(decoded).(*BasicIrr)
+	assert.Equal(t, []string{"users", "replica"}, decodedInner.GetTag("db"))
+	assert.Equal(t, inner.GetTraceInfo().Frames(), decodedInner.GetTraceInfo().Frames())
+	assert.Equal(t, []slog.Attr{slog.Duration("elapsed", 3*time.Second), slog.Int64("retries", 3)}, decodedInner.Attrs())
+	assert.Equal(t, "connection refused", errors.Unwrap(errors.Unwrap(decodedInner)).Error())
+
+	// 与 JSON 编码的内容一致
+	want, _ := MarshalErrorJSON(outer)
+	got, _ := MarshalErrorJSON(decoded)
+	assert.JSONEq(t, string(want), string(got))
+}
+
+func TestEncodeDecode_Kinds(t *testing.T) {
+	assert.Nil(t, wireRoundTrip(t, nil))
+
+	// 空的 BasicIrr 与空的聚合错误都能保留类型
+	empty := wireRoundTrip(t, &BasicIrr{})
+	assert.IsType(t, &BasicIrr{}, empty)
+	assert.IsType(t, &MultiIrr{}, wireRoundTrip(t, &MultiIrr{}))
+
+	joined := Wrap(JoinWith(PickMaxCode, ErrorC(400, "a"), errors.New("b")), "batch")
+	decoded := wireRoundTrip(t, joined)
+	assert.Equal(t, joined.Error(), decoded.Error())
+	assert.True(t, errors.Is(decoded, ErrorC(400, "")))
+
+	ctxErr := ErrorWithContext(context.Background(), "with ctx")
+	assert.Equal(t, "with ctx", wireRoundTrip(t, ctxErr).Error())
+
+	// 同一个流中连续编码多个错误
+	buf := &bytes.Buffer{}
+	assert.NoError(t, Encode(buf, Error("first")))
+	assert.NoError(t, Encode(buf, nil))
+	assert.NoError(t, Encode(buf, Error("third")))
+	r := io.MultiReader(buf) // 不实现 io.ByteReader
+	for _, want := range []string{"first", "", "third"} {
+		decoded, err := Decode(r)
+		assert.NoError(t, err)
+		if want == "" {
+			assert.Nil(t, decoded.Err)
+		} else {
+			assert.Equal(t, want, decoded.Err.Error())
+		}
+	}
+	_, err := Decode(r)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDecode_UnknownFields(t *testing.T) {
+	// 模拟新版本写出的数据：节点与 tag 中都带有未知字段
+	var tag []byte
+	tag = appendWireString(tag, wireTagKey, "k")
+	tag = appendWireVarint(tag, 15, 7)
+	tag = appendWireString(tag, wireTagValue, "v")
+
+	var node []byte
+	node = appendWireVarint(node, wireNodeKind, wireKindBasic)
+	node = appendWireBytes(node, 20, []byte("future payload"))
+	node = appendWireString(node, wireNodeMsg, "from the future")
+	node = appendWireVarint(node, 21, 1<<40)
+	node = appendWireBytes(node, wireNodeTag, tag)
+	node = appendWireVarint(node, wireNodeCode, zigzag(404))
+
+	data := append([]byte("IRR"), WireVersion)
+	data = binary.AppendUvarint(data, uint64(len(node)))
+	data = append(data, node...)
+
+	decoded, err := Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, "code(404), from the future[k:v] ", decoded.Err.Error())
+
+	// 未知的节点种类按 BasicIrr 解码
+	node = appendWireVarint(nil, wireNodeKind, 99)
+	node = appendWireString(node, wireNodeMsg, "unknown kind")
+	data = append([]byte("IRR"), WireVersion)
+	data = binary.AppendUvarint(data, uint64(len(node)))
+	decoded, err = Decode(bytes.NewReader(append(data, node...)))
+	assert.NoError(t, err)
+	assert.Equal(t, "unknown kind", decoded.Err.Error())
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	valid := &bytes.Buffer{}
+	assert.NoError(t, Encode(valid, Wrap(ErrorC(500, "inner"), "outer")))
+	data := valid.Bytes()
+
+	_, err := Decode(bytes.NewReader([]byte("JSON")))
+	assert.ErrorIs(t, err, ErrInvalidWire)
+
+	_, err = Decode(bytes.NewReader([]byte{'I', 'R', 'R', WireVersion + 1, 0}))
+	assert.ErrorIs(t, err, ErrUnsupportedWireVersion)
+
+	_, err = Decode(bytes.NewReader(data[:len(data)-1]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = Decode(bytes.NewReader(data[:4]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = Decode(bytes.NewReader(data[:2]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	tooLarge := binary.AppendUvarint([]byte{'I', 'R', 'R', WireVersion}, MaxWireSize+1)
+	_, err = Decode(bytes.NewReader(tooLarge))
+	assert.ErrorIs(t, err, ErrInvalidWire)
+
+	// 长度前缀声明了最大的负载却没有数据时，不会预先分配整个负载
+	truncated := binary.AppendUvarint([]byte{'I', 'R', 'R', WireVersion}, MaxWireSize)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = Decode(bytes.NewReader(truncated))
+	runtime.ReadMemStats(&after)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+
+	// 已知字段使用了错误的类型
+	node := appendWireString(nil, wireNodeCode, "404")
+	bad := binary.AppendUvarint([]byte{'I', 'R', 'R', WireVersion}, uint64(len(node)))
+	_, err = Decode(bytes.NewReader(append(bad, node...)))
+	assert.ErrorIs(t, err, ErrInvalidWire)
+
+	// 超过深度限制的嵌套
+	node = appendWireVarint(nil, wireNodeKind, wireKindBasic)
+	for i := 0; i <= maxWireDepth; i++ {
+		node = appendWireBytes(nil, wireNodeInner, node)
+	}
+	deep := binary.AppendUvarint([]byte{'I', 'R', 'R', WireVersion}, uint64(len(node)))
+	_, err = Decode(bytes.NewReader(append(deep, node...)))
+	assert.ErrorIs(t, err, ErrInvalidWire)
+}
+
+func TestZigzag(t *testing.T) {
+	for _, v := range []int64{0, 1, -1, 404, -5001, 1<<63 - 1, -1 << 63} {
+		assert.Equal(t, v, unzigzag(zigzag(v)))
+	}
+	assert.Equal(t, uint64(1), zigzag(-1))
+	assert.Equal(t, uint64(2), zigzag(1))
+}
+
+func FuzzDecode(f *testing.F) {
+	seeds := []error{
+		nil,
+		Error("plain"),
+		TrackFull(errors.New("root"), "tracked").SetCode(500),
+		Wrap(Join(ErrorC(400, "a"), errors.New("b")), "batch"),
+	}
+	for _, seed := range seeds {
+		buf := &bytes.Buffer{}
+		if err := Encode(buf, seed); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+	withAttr := Error("attrs")
+	withAttr.SetAttr("group", slog.GroupValue(slog.Int("n", 1)))
+	withAttr.SetTag("k", "v")
+	buf := &bytes.Buffer{}
+	_ = Encode(buf, withAttr)
+	f.Add(buf.Bytes())
+	f.Add([]byte("IRR"))
+	f.Add([]byte{'I', 'R', 'R', WireVersion, 2, 0x08, 0x02})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		result, err := Decode(bytes.NewReader(data))
+		decoded := result.Err
+		if err != nil || decoded == nil {
+			return
+		}
+		// 解码成功的错误可以被渲染，并再次稳定地编码
+		_ = decoded.Error()
+		_ = fmt.Sprintf("%+v", decoded)
+		if _, jErr := json.Marshal(decoded); jErr != nil {
+			t.Fatal(jErr)
+		}
+		again := &bytes.Buffer{}
+		if eErr := Encode(again, decoded); eErr != nil {
+			t.Fatal(eErr)
+		}
+		if _, dErr := Decode(again); dErr != nil {
+			t.Fatalf("re-encoded error cannot be decoded: %v", dErr)
+		}
+	})
+}